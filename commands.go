@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonutz/structorama/lint"
+	"github.com/gonutz/structorama/metrics"
+	"github.com/gonutz/structorama/parser"
+)
+
+const usage = `Usage:
+
+	structorama
+		Starts the GUI (Windows only).
+
+	structorama render [flags] [input]
+		Renders a diagram to a PNG, SVG or PDF file or encodes it as JSON.
+		Reads from stdin if no input file is given. Run "structorama render -h"
+		for the flags.
+
+	structorama fmt [flags] [path ...]
+		Formats diagram files. Directories are searched recursively for .nsd
+		files. Reads from stdin if no path is given. Run "structorama fmt -h"
+		for the flags.
+
+	structorama lint [flags] [path ...]
+		Reports diagrams that parse but do not make sense, like a break outside
+		of a loop. Paths are handled like for fmt. Run "structorama lint -h"
+		for the flags and rules.
+
+	structorama stats [flags] [path ...]
+		Prints the size and complexity of diagrams, as text or JSON. Paths are
+		handled like for fmt. Run "structorama stats -h" for the flags.
+`
+
+// runCommand runs the command line tool with the given arguments, not
+// including the program name. It returns the program's exit code.
+func runCommand(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	switch args[0] {
+	case "render":
+		return runRender(args[1:], stdin, stdout, stderr)
+	case "fmt":
+		return runFmt(args[1:], stdin, stdout, stderr)
+	case "lint":
+		return runLint(args[1:], stdin, stdout, stderr)
+	case "stats":
+		return runStats(args[1:], stdin, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+func runRender(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output `file`, its extension selects the format, writes to stdout if empty")
+	format := flags.String("format", "", "output `format`: png, svg, pdf or json, overrides the output file's extension")
+	fontPath := flags.String("font", "", "TrueType font `file` used for the text in PNG and PDF output")
+	pageSize := flags.String("page", "A4", "PDF page `size`: A4, A3, Letter or auto to fit the page to the diagram")
+	landscape := flags.Bool("landscape", false, "use landscape PDF pages")
+	jsonInput := flags.Bool("json", false, "read the input as JSON instead of code, this is the default for .json files")
+	multiBranch := flags.Bool("multi-branch", false, "paint else-if chains as one decision like a switch instead of nested ifs")
+	inputs, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	}
+	if len(inputs) > 1 {
+		fmt.Fprintln(stderr, "render: only one input file is allowed")
+		return 2
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*output), ".")
+	}
+	*format = strings.ToLower(*format)
+	export, ok := exporters[*format]
+	if !ok {
+		if *format == "" {
+			fmt.Fprintln(stderr, "render: no output format, use -o with a file extension or -format")
+		} else {
+			fmt.Fprintf(stderr, "render: unknown output format %q\n", *format)
+		}
+		return 2
+	}
+
+	name, code, err := readInput(inputs, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	s, errs := parseInput(name, code, *jsonInput)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		}
+		return 1
+	}
+
+	// Render into memory first so we do not leave a broken file behind.
+	var buf bytes.Buffer
+	if err := export(&buf, s, exportOptions{
+		fontPath:  *fontPath,
+		pageSize:  *pageSize,
+		landscape: *landscape,
+		paint:     paintSettings{multiBranchIfChains: *multiBranch},
+	}); err != nil {
+		fmt.Fprintln(stderr, "render:", err)
+		return 1
+	}
+	if *output == "" || *output == "-" {
+		_, err = stdout.Write(buf.Bytes())
+	} else {
+		err = os.WriteFile(*output, buf.Bytes(), 0666)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "render:", err)
+		return 1
+	}
+	return 0
+}
+
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to the source file instead of stdout")
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	diff := flags.Bool("d", false, "print diffs instead of the formatted code")
+	paths, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	}
+
+	exitCode := 0
+	report := func(err error) {
+		fmt.Fprintln(stderr, err)
+		exitCode = 2
+	}
+
+	// process formats the code and writes the result according to the flags.
+	// writeFile is nil for stdin.
+	process := func(name, code string, writeFile func(string) error) {
+		formatted, err := parser.FormatString(code)
+		if err != nil {
+			report(fmt.Errorf("%s: %v", name, err))
+			return
+		}
+		changed := formatted != code
+		if *list && changed {
+			fmt.Fprintln(stdout, name)
+		}
+		if *write && changed {
+			if err := writeFile(formatted); err != nil {
+				report(err)
+			}
+		}
+		if *diff && changed {
+			fmt.Fprint(stdout, unifiedDiff(name+".orig", name, code, formatted))
+		}
+		if !*list && !*write && !*diff {
+			fmt.Fprint(stdout, formatted)
+		}
+	}
+
+	processFile := func(path string) {
+		info, err := os.Stat(path)
+		if err != nil {
+			report(err)
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			report(err)
+			return
+		}
+		process(path, string(data), func(formatted string) error {
+			return os.WriteFile(path, []byte(formatted), info.Mode().Perm())
+		})
+	}
+
+	if len(paths) == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "fmt: cannot use -w with standard input")
+			return 2
+		}
+		_, code, err := readInput(nil, stdin)
+		if err != nil {
+			report(err)
+			return exitCode
+		}
+		process("<stdin>", code, nil)
+		return exitCode
+	}
+
+	walkPaths(paths, processFile, report)
+	return exitCode
+}
+
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	enable := flags.String("enable", "", "comma-separated `rules` to check, all rules if empty")
+	disable := flags.String("disable", "", "comma-separated `rules` not to check")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage of lint:")
+		flags.PrintDefaults()
+		fmt.Fprintln(stderr, "Rules:")
+		for _, rule := range lint.Rules {
+			fmt.Fprintf(stderr, "  %s\n    \t%s\n", rule.ID, rule.Description)
+		}
+	}
+	paths, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	}
+	config, err := lintConfig(*enable, *disable)
+	if err != nil {
+		fmt.Fprintln(stderr, "lint:", err)
+		return 2
+	}
+
+	// The exit code is 1 if there are warnings and 2 if there are errors.
+	exitCode := 0
+	report := func(err error) {
+		fmt.Fprintln(stderr, err)
+		exitCode = 2
+	}
+
+	process := func(name, code string) {
+		s, err := parser.ParseString(code)
+		if err != nil {
+			report(fmt.Errorf("%s: %v", name, err))
+			return
+		}
+		for _, w := range lint.Lint(s, config) {
+			fmt.Fprintf(stdout, "%s:%v\n", name, w)
+			if exitCode == 0 {
+				exitCode = 1
+			}
+		}
+	}
+
+	if len(paths) == 0 {
+		_, code, err := readInput(nil, stdin)
+		if err != nil {
+			report(err)
+			return exitCode
+		}
+		process("<stdin>", code)
+		return exitCode
+	}
+
+	walkPaths(paths, func(path string) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			report(err)
+			return
+		}
+		process(path, string(data))
+	}, report)
+	return exitCode
+}
+
+// lintConfig creates the lint configuration from the comma-separated lists of
+// rules to enable and disable. If rules are enabled, all others are disabled.
+func lintConfig(enable, disable string) (lint.Config, error) {
+	known := map[string]bool{}
+	config := lint.Config{}
+	for _, rule := range lint.Rules {
+		known[rule.ID] = true
+		config[rule.ID] = enable == ""
+	}
+	set := func(list string, enabled bool) error {
+		if list == "" {
+			return nil
+		}
+		for _, rule := range strings.Split(list, ",") {
+			rule = strings.TrimSpace(rule)
+			if !known[rule] {
+				return fmt.Errorf("unknown rule %q", rule)
+			}
+			config[rule] = enabled
+		}
+		return nil
+	}
+	if err := set(enable, true); err != nil {
+		return nil, err
+	}
+	if err := set(disable, false); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// walkPaths calls process for every path that is a file and for every .nsd
+// file in the directories, recursively.
+func walkPaths(paths []string, process func(path string), report func(error)) {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if !info.IsDir() {
+			process(path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				report(err)
+			} else if !d.IsDir() && filepath.Ext(path) == ".nsd" {
+				process(path)
+			}
+			return nil
+		})
+		if err != nil {
+			report(err)
+		}
+	}
+}
+
+// parseFlags is like flags.Parse but it also allows flags after the
+// positional arguments, e.g. "render in.nsd -o out.png". It returns the
+// positional arguments.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func runStats(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output `format`: text or json")
+	jsonInput := flags.Bool("json", false, "read the input as JSON instead of code, this is the default for .json files")
+	paths, err := parseFlags(flags, args)
+	if err != nil {
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "stats: unknown output format %q\n", *format)
+		return 2
+	}
+
+	exitCode := 0
+	report := func(err error) {
+		fmt.Fprintln(stderr, err)
+		exitCode = 1
+	}
+
+	all := []diagramStats{}
+	process := func(name, code string) {
+		s, errs := parseInput(name, code, *jsonInput)
+		if len(errs) > 0 {
+			for _, err := range errs {
+				report(fmt.Errorf("%s: %v", name, err))
+			}
+			return
+		}
+		stats := diagramStats{File: name, Metrics: metrics.Compute(s)}
+		stats.Width, stats.Height = structogramSize(newSVGPainter(exportFontSize), paintSettings{}, s)
+		all = append(all, stats)
+	}
+
+	if len(paths) == 0 {
+		name, code, err := readInput(nil, stdin)
+		if err != nil {
+			report(err)
+			return exitCode
+		}
+		process(name, code)
+	} else {
+		walkPaths(paths, func(path string) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				report(err)
+				return
+			}
+			process(path, string(data))
+		}, report)
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(all); err != nil {
+			report(err)
+		}
+	} else {
+		for _, s := range all {
+			fmt.Fprintf(
+				stdout,
+				"%s: statements %d, max depth %d, complexity %d, breaks %d, calls %d, size %dx%d\n",
+				s.File, s.Statements, s.MaxDepth, s.Complexity, s.Breaks, s.Calls, s.Width, s.Height,
+			)
+		}
+	}
+	return exitCode
+}
+
+// diagramStats are the metrics of a diagram file and the size in pixels that
+// it is rendered with.
+type diagramStats struct {
+	File string `json:"file"`
+	metrics.Metrics
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// parseInput parses the code of the input with the given name. The code is
+// JSON if asJSON is set or the name has the extension .json.
+func parseInput(name, code string, asJSON bool) (*parser.Structogram, []error) {
+	if asJSON || strings.ToLower(filepath.Ext(name)) == ".json" {
+		s := &parser.Structogram{}
+		if err := json.Unmarshal([]byte(code), s); err != nil {
+			return nil, []error{err}
+		}
+		return s, nil
+	}
+	s, errs := parser.ParsePartial(code)
+	var all []error
+	for _, err := range errs {
+		all = append(all, err)
+	}
+	return s, all
+}
+
+// readInput reads the single input file or stdin if there is none or if it is
+// "-". It returns the name to use in error messages along with the code.
+func readInput(inputs []string, stdin io.Reader) (name, code string, err error) {
+	if len(inputs) == 0 || inputs[0] == "-" {
+		data, err := io.ReadAll(stdin)
+		return "<stdin>", string(data), err
+	}
+	data, err := os.ReadFile(inputs[0])
+	return inputs[0], string(data), err
+}
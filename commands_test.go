@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+
+	"github.com/gonutz/structorama/metrics"
+	"github.com/gonutz/structorama/parser"
+)
+
+func TestRenderReportsParseErrorsWithExitCode1(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(
+		[]string{"render", "-format", "png"},
+		strings.NewReader(`switch "" {`),
+		&stdout, &stderr,
+	)
+	check.Eq(t, code, 1)
+	check.Eq(t, stdout.String(), "")
+	check.Eq(t, stderr.String(), "<stdin>: parse error: 1:12: token '}' expected but found end of input\n")
+}
+
+func TestRenderReportsAllParseErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(
+		[]string{"render", "-format", "svg"},
+		strings.NewReader("whlie \"a\"\nif {}"),
+		&stdout, &stderr,
+	)
+	check.Eq(t, code, 1)
+	check.Eq(t, stdout.String(), "")
+	check.Eq(t, stderr.String(), `<stdin>: parse error: 1:1: statement expected but found identifier "whlie", did you mean "while"?
+<stdin>: parse error: 2:4: string expected but found token '{'
+`)
+}
+
+func TestRenderNeedsKnownOutputFormat(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(
+		[]string{"render", "-o", "diagram.gif"},
+		strings.NewReader(`"a"`),
+		&stdout, &stderr,
+	)
+	check.Eq(t, code, 2)
+	check.Eq(t, stderr.String(), "render: unknown output format \"gif\"\n")
+
+	stderr.Reset()
+	code = runCommand([]string{"render"}, strings.NewReader(`"a"`), &stdout, &stderr)
+	check.Eq(t, code, 2)
+	check.Eq(t, stderr.String(),
+		"render: no output format, use -o with a file extension or -format\n")
+}
+
+func TestUnknownCommandPrintsUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"paint"}, nil, &stdout, &stderr)
+	check.Eq(t, code, 2)
+	check.Eq(t, stderr.String(), "unknown command \"paint\"\n\n"+usage)
+}
+
+func TestFlagsCanComeAfterPositionalArguments(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	o := flags.String("o", "", "")
+	args, err := parseFlags(flags, []string{"in.nsd", "-o", "out.png", "other"})
+	check.Eq(t, err, nil)
+	check.Eq(t, args, []string{"in.nsd", "other"})
+	check.Eq(t, *o, "out.png")
+}
+
+func TestFmtFormatsStdinToStdout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(
+		[]string{"fmt"},
+		strings.NewReader(`if"a"{"b"}`),
+		&stdout, &stderr,
+	)
+	check.Eq(t, code, 0)
+	check.Eq(t, stdout.String(), "if \"a\" {\n\t\"b\"\n}\n")
+	check.Eq(t, stderr.String(), "")
+}
+
+func TestFmtListsAndRewritesFilesInDirectories(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	check.Eq(t, os.Mkdir(sub, 0777), nil)
+	formatted := filepath.Join(dir, "formatted.nsd")
+	unformatted := filepath.Join(sub, "unformatted.nsd")
+	other := filepath.Join(sub, "other.txt")
+	check.Eq(t, os.WriteFile(formatted, []byte("\"a\"\n"), 0666), nil)
+	check.Eq(t, os.WriteFile(unformatted, []byte(` "a"`), 0666), nil)
+	check.Eq(t, os.WriteFile(other, []byte(` "a"`), 0666), nil)
+
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"fmt", "-l", "-w", dir}, nil, &stdout, &stderr)
+	check.Eq(t, code, 0)
+	check.Eq(t, stdout.String(), unformatted+"\n")
+	check.Eq(t, stderr.String(), "")
+
+	data, _ := os.ReadFile(unformatted)
+	check.Eq(t, string(data), "\"a\"\n")
+	data, _ = os.ReadFile(other)
+	check.Eq(t, string(data), ` "a"`)
+}
+
+func TestFmtReportsParseErrorsWithExitCode2(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"fmt", "-d"}, strings.NewReader(`switch "" {`), &stdout, &stderr)
+	check.Eq(t, code, 2)
+	check.Eq(t, stdout.String(), "")
+	check.Eq(t, stderr.String(), "<stdin>: parse error: 1:12: token '}' expected but found end of input\n")
+}
+
+func TestRenderCanPaintIfChainsAsOneDecision(t *testing.T) {
+	code := `if "a" {} else if "b" {} else {}`
+	render := func(args ...string) string {
+		var stdout, stderr bytes.Buffer
+		exitCode := runCommand(
+			append([]string{"render", "-format", "svg"}, args...),
+			strings.NewReader(code),
+			&stdout, &stderr,
+		)
+		check.Eq(t, exitCode, 0)
+		check.Eq(t, stderr.String(), "")
+		return stdout.String()
+	}
+	nested := render()
+	multiBranch := render("-multi-branch")
+	check.Eq(t, strings.Contains(nested, ">else<"), false)
+	check.Eq(t, strings.Contains(multiBranch, ">else<"), true)
+}
+
+func TestRenderSVGNeedsNoFontFile(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(
+		[]string{"render", "-format", "svg", "-font", "does/not/exist.ttf"},
+		strings.NewReader(`"a"`),
+		&stdout, &stderr,
+	)
+	check.Eq(t, code, 0)
+	check.Eq(t, stderr.String(), "")
+	check.Eq(t, strings.HasPrefix(stdout.String(), "<?xml"), true)
+	check.Eq(t, strings.HasSuffix(stdout.String(), "</svg>\n"), true)
+}
+
+func TestRenderJSONRoundTrip(t *testing.T) {
+	const code = `title "t"
+if "a" { "b" } else { call "c" }`
+
+	var jsonOut, stderr bytes.Buffer
+	exit := runCommand(
+		[]string{"render", "-format", "json"},
+		strings.NewReader(code),
+		&jsonOut, &stderr,
+	)
+	check.Eq(t, exit, 0)
+	check.Eq(t, stderr.String(), "")
+	check.Eq(t, strings.Contains(jsonOut.String(), `"kind": "ifElse"`), true)
+
+	var fromCode, fromJSON bytes.Buffer
+	exit = runCommand(
+		[]string{"render", "-format", "svg"},
+		strings.NewReader(code),
+		&fromCode, &stderr,
+	)
+	check.Eq(t, exit, 0)
+	exit = runCommand(
+		[]string{"render", "-json", "-format", "svg"},
+		&jsonOut,
+		&fromJSON, &stderr,
+	)
+	check.Eq(t, exit, 0)
+	check.Eq(t, stderr.String(), "")
+	check.Eq(t, fromJSON.String(), fromCode.String())
+}
+
+func TestRenderReportsInvalidJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	exit := runCommand(
+		[]string{"render", "-json", "-format", "svg"},
+		strings.NewReader(`{"statements":[{"kind":"goto"}]}`),
+		&stdout, &stderr,
+	)
+	check.Eq(t, exit, 1)
+	check.Eq(t, stderr.String(), "<stdin>: unknown statement kind \"goto\"\n")
+}
+
+func TestLintReportsWarningsWithExitCode1(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(
+		[]string{"lint"},
+		strings.NewReader("switch \"a\" {}\nbreak \"\""),
+		&stdout, &stderr,
+	)
+	check.Eq(t, code, 1)
+	check.Eq(t, stdout.String(), "<stdin>:1:1: switch has no cases (empty-switch)\n"+
+		"<stdin>:2:1: break outside of a loop (break-outside-loop)\n")
+	check.Eq(t, stderr.String(), "")
+}
+
+func TestLintRulesCanBeEnabledAndDisabled(t *testing.T) {
+	const diagram = "parallel {}\nswitch \"a\" {}\nbreak \"\""
+	lintWith := func(flags ...string) string {
+		var stdout, stderr bytes.Buffer
+		runCommand(
+			append([]string{"lint"}, flags...),
+			strings.NewReader(diagram),
+			&stdout, &stderr,
+		)
+		check.Eq(t, stderr.String(), "")
+		return stdout.String()
+	}
+	check.Eq(t, lintWith("-enable", "empty-switch"),
+		"<stdin>:2:1: switch has no cases (empty-switch)\n")
+	check.Eq(t, lintWith("-disable", "empty-switch,trivial-parallel"),
+		"<stdin>:3:1: break outside of a loop (break-outside-loop)\n")
+}
+
+func TestLintRejectsUnknownRules(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"lint", "-disable", "tabs"}, nil, &stdout, &stderr)
+	check.Eq(t, code, 2)
+	check.Eq(t, stderr.String(), "lint: unknown rule \"tabs\"\n")
+}
+
+func TestLintWithoutWarnings(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"lint"}, strings.NewReader(`"a"`), &stdout, &stderr)
+	check.Eq(t, code, 0)
+	check.Eq(t, stdout.String(), "")
+	check.Eq(t, stderr.String(), "")
+}
+
+func TestStatsPrintsMetricsAndSize(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(
+		[]string{"stats"},
+		strings.NewReader(`while "x" { if "y" { break "" } }`),
+		&stdout, &stderr,
+	)
+	check.Eq(t, code, 0)
+	check.Eq(t, stderr.String(), "")
+	check.Eq(t, strings.HasPrefix(stdout.String(),
+		"<stdin>: statements 3, max depth 3, complexity 3, breaks 1, calls 0, size "), true)
+}
+
+func TestStatsAsJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(
+		[]string{"stats", "-format", "json"},
+		strings.NewReader(`call "a"`),
+		&stdout, &stderr,
+	)
+	check.Eq(t, code, 0)
+	check.Eq(t, stderr.String(), "")
+
+	var stats []diagramStats
+	check.Eq(t, json.Unmarshal(stdout.Bytes(), &stats), nil)
+	check.Eq(t, len(stats), 1)
+	check.Eq(t, stats[0].File, "<stdin>")
+	check.Eq(t, stats[0].Metrics, metrics.Metrics{Statements: 1, MaxDepth: 1, Complexity: 1, Calls: 1})
+
+	s, err := parser.ParseString(`call "a"`)
+	check.Eq(t, err, nil)
+	width, height := structogramSize(newSVGPainter(exportFontSize), paintSettings{}, s)
+	check.Eq(t, stats[0].Width, width)
+	check.Eq(t, stats[0].Height, height)
+}
+
+func TestStatsReportsParseErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand([]string{"stats"}, strings.NewReader(`"a`), &stdout, &stderr)
+	check.Eq(t, code, 1)
+	check.Eq(t, stdout.String(), "")
+	check.Eq(t, stderr.String(), "<stdin>: parse error: 1:3: closing '\"' of string expected but found end of input\n")
+}
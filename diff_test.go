@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+)
+
+func TestEqualTextsHaveNoDiff(t *testing.T) {
+	check.Eq(t, unifiedDiff("a", "b", "same\n", "same\n"), "")
+}
+
+func TestDiffShowsChangesWithThreeLinesOfContext(t *testing.T) {
+	check.Eq(t, unifiedDiff("old", "new",
+		"1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+		"1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+	), `--- old
++++ new
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`)
+}
+
+func TestCloseChangesAreMergedIntoOneHunk(t *testing.T) {
+	check.Eq(t, unifiedDiff("old", "new",
+		"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n",
+		"A\nb\nc\nd\ne\nf\ng\nH\ni\nj\nk\nl\nm\n",
+	), `--- old
++++ new
+@@ -1,11 +1,11 @@
+-a
++A
+ b
+ c
+ d
+ e
+ f
+ g
+-h
++H
+ i
+ j
+ k
+`)
+
+	check.Eq(t, unifiedDiff("old", "new",
+		"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n",
+		"A\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n",
+	), `--- old
++++ new
+@@ -1,4 +1,4 @@
+-a
++A
+ b
+ c
+ d
+@@ -7,4 +7,4 @@
+ g
+ h
+ i
+-j
++J
+`)
+}
+
+func TestDiffMarksMissingNewlineAtEndOfFile(t *testing.T) {
+	check.Eq(t, unifiedDiff("old", "new", "", "a"), `--- old
++++ new
+@@ -0,0 +1,1 @@
++a
+\ No newline at end of file
+`)
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/gonutz/gofont"
+
+	"github.com/gonutz/structorama/parser"
+)
+
+// exportFontHeight is the text height in pixels used for exported diagrams.
+const exportFontHeight = 20
+
+// exportFontSize is the font size used for vector output. Its line height
+// matches exportFontHeight.
+const exportFontSize = exportFontHeight / textLineSpacing
+
+// exportMargin is the empty space in pixels around exported diagrams.
+const exportMargin = 10
+
+// exportOptions configure how diagrams are exported.
+type exportOptions struct {
+	// fontPath is the TrueType font used for PNG and PDF output, see loadFont
+	// and loadVectorFont.
+	fontPath string
+	// pageSize is the PDF page size, one of the pdfPageSizes or "auto" for a
+	// page that fits the diagram. It defaults to A4.
+	pageSize string
+	// landscape turns the PDF page sideways. It has no effect on auto pages.
+	landscape bool
+	// paint are the settings for painting PNG, SVG and PDF output.
+	paint paintSettings
+}
+
+// pdfPageSizes are the supported PDF page sizes in points, in portrait
+// orientation. The keys are lower case.
+var pdfPageSizes = map[string]gofpdf.SizeType{
+	"a4":     {Wd: 595.28, Ht: 841.89},
+	"a3":     {Wd: 841.89, Ht: 1190.55},
+	"letter": {Wd: 612, Ht: 792},
+}
+
+// pdfPageMargin is the empty space around the diagram on PDF pages, in points.
+const pdfPageMargin = 28.35 // 1 cm
+
+// defaultFontPaths are tried in order when no font is given explicitly.
+var defaultFontPaths = []string{
+	"C:/Windows/Fonts/Tahoma.ttf",
+	"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+	"/usr/share/fonts/TTF/DejaVuSans.ttf",
+	"/usr/share/fonts/truetype/liberation/LiberationSans-Regular.ttf",
+	"/Library/Fonts/Arial.ttf",
+	"/System/Library/Fonts/Supplemental/Arial.ttf",
+}
+
+// loadFont loads the TrueType font at the given path. If path is empty, the
+// first of the defaultFontPaths that exists is used.
+func loadFont(path string) (*gofont.Font, error) {
+	paths := []string{path}
+	if path == "" {
+		paths = defaultFontPaths
+	}
+	for _, path := range paths {
+		font, err := gofont.LoadFromFile(path)
+		if err == nil {
+			font.HeightInPixels = exportFontHeight
+			return font, nil
+		}
+		if len(paths) == 1 {
+			return nil, err
+		}
+	}
+	return nil, errors.New("no default font found, please specify a .ttf file")
+}
+
+// exporters maps the supported output formats to their export functions.
+var exporters = map[string]func(io.Writer, *parser.Structogram, exportOptions) error{
+	"png":  writePNG,
+	"svg":  writeSVG,
+	"pdf":  writePDF,
+	"json": writeJSON,
+}
+
+// renderImage paints the structogram into an image that fits the whole
+// diagram.
+func renderImage(s *parser.Structogram, font *gofont.Font, settings paintSettings) *image.RGBA {
+	width, height := structogramSize(imagePainter{font: font}, settings, s)
+	img := image.NewRGBA(image.Rect(
+		0, 0, width+2*exportMargin, height+2*exportMargin,
+	))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	paintStructogram(
+		offsetPainter{
+			p:  imagePainter{img: img, font: font},
+			dx: exportMargin,
+			dy: exportMargin,
+		},
+		settings,
+		s,
+	)
+	return img
+}
+
+func writePNG(w io.Writer, s *parser.Structogram, options exportOptions) error {
+	font, err := loadFont(options.fontPath)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, renderImage(s, font, options.paint))
+}
+
+func writeSVG(w io.Writer, s *parser.Structogram, options exportOptions) error {
+	p := newSVGPainter(exportFontSize)
+	width, height := structogramSize(p, options.paint, s)
+	width += 2 * exportMargin
+	height += 2 * exportMargin
+	paintStructogram(offsetPainter{p: p, dx: exportMargin, dy: exportMargin}, options.paint, s)
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="%s" font-size="%s" stroke-linecap="square" xml:space="preserve">
+<rect width="100%%" height="100%%" fill="white"/>
+%s</svg>
+`,
+		width, height, width, height,
+		svgFontFamily, svgNumber(exportFontSize),
+		p.elements.Bytes(),
+	)
+	return err
+}
+
+// writeJSON writes the structogram in the JSON encoding of the parser package.
+func writeJSON(w io.Writer, s *parser.Structogram, options exportOptions) error {
+	data, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func writePDF(w io.Writer, s *parser.Structogram, options exportOptions) error {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	font, err := loadVectorFont(pdf, exportFontSize, options.fontPath)
+	if err != nil {
+		return err
+	}
+	p := newPDFPainter(pdf, font)
+
+	pages := []pdfPage{{diagram: s}}
+	format, auto, err := pdfPageFormat(options)
+	if err != nil {
+		return err
+	}
+	if !auto {
+		// Diagrams are scaled to fit the page width, if that makes them too
+		// high for the page, we split them into multiple pages.
+		width, _ := structogramSize(p, options.paint, s)
+		scale := math.Min(1, (format.Wd-2*pdfPageMargin)/float64(width+2))
+		maxHeight := int((format.Ht - 2*pdfPageMargin) / scale)
+		pages = splitPages(p, options.paint, s, maxHeight)
+	}
+
+	// All pages are painted with the same width as the whole diagram.
+	bodyW, _ := minSize(p, options.paint, parser.Block{Statements: s.Statements})
+	for _, page := range pages {
+		width, height := structogramSize(p, options.paint, page.diagram)
+		width = max(width, bodyW)
+		if page.continued {
+			height += pdfContinuedGap + p.LineHeight()
+		}
+		// The diagram's border is painted one pixel outside of its area.
+		format, scale, x, y, err := pdfPageLayout(
+			options, float64(width+2), float64(height+2),
+		)
+		if err != nil {
+			return err
+		}
+		pdf.AddPageFormat("P", format)
+		pdf.TransformBegin()
+		pdf.TransformTranslate(x, y)
+		pdf.TransformScale(100*scale, 100*scale, 0, 0)
+		paintStructogramWidth(offsetPainter{p: p, dx: 1, dy: 1}, options.paint, page.diagram, bodyW)
+		if page.continued {
+			p.Text(1, height+2-p.LineHeight(), pdfContinuedMarker)
+		}
+		pdf.TransformEnd()
+	}
+	return pdf.Output(w)
+}
+
+// pdfContinuedMarker is printed below diagrams that continue on the next page.
+const pdfContinuedMarker = "continued on next page"
+
+// pdfContinuedGap is the space between a diagram and its pdfContinuedMarker.
+const pdfContinuedGap = 5
+
+// continuedTitle is the title of pages that continue a diagram.
+func continuedTitle(title string) string {
+	if title == "" {
+		return "(continued)"
+	}
+	return title + " (continued)"
+}
+
+// pdfPage is the part of a diagram that is printed on a single PDF page.
+type pdfPage struct {
+	diagram *parser.Structogram
+	// continued is true for all but the last page.
+	continued bool
+}
+
+// splitPages splits the diagram at its top-level statements into pages that
+// are at most maxHeight pixels high when painted. All pages after the first
+// repeat the title with a note that they are a continuation. A statement that
+// is higher than a page on its own gets a page of its own.
+func splitPages(p painter, settings paintSettings, s *parser.Structogram, maxHeight int) []pdfPage {
+	if _, height := structogramSize(p, settings, s); height+2 <= maxHeight {
+		return []pdfPage{{diagram: s}}
+	}
+
+	// Every page has room for a title, the diagram's border and the
+	// continuation marker.
+	_, titleH := p.TextSize(continuedTitle(s.Title.Text))
+	free := maxHeight - (titleH + 5 + 2 + pdfContinuedGap + p.LineHeight())
+
+	var parts [][]parser.Statement
+	var part []parser.Statement
+	partH := 0
+	for _, stmt := range s.Statements {
+		_, h := minSize(p, settings, stmt)
+		if len(part) > 0 && partH+1+h > free {
+			parts = append(parts, part)
+			part, partH = nil, 0
+		}
+		if len(part) > 0 {
+			partH++ // The line between statements.
+		}
+		part = append(part, stmt)
+		partH += h
+	}
+	parts = append(parts, part)
+
+	pages := make([]pdfPage, len(parts))
+	for i, part := range parts {
+		title := s.Title
+		if i > 0 {
+			title = parser.String{Text: continuedTitle(s.Title.Text)}
+		}
+		pages[i] = pdfPage{
+			diagram:   &parser.Structogram{Title: title, Statements: part},
+			continued: i+1 < len(parts),
+		}
+	}
+	return pages
+}
+
+// pdfPageFormat returns the page size selected in the options. auto is true if
+// the page size is to be chosen to fit the diagram.
+func pdfPageFormat(options exportOptions) (page gofpdf.SizeType, auto bool, err error) {
+	pageSize := strings.ToLower(options.pageSize)
+	if pageSize == "auto" {
+		return page, true, nil
+	}
+	if pageSize == "" {
+		pageSize = "a4"
+	}
+	page, ok := pdfPageSizes[pageSize]
+	if !ok {
+		return page, false, fmt.Errorf("unknown page size %q", options.pageSize)
+	}
+	if options.landscape {
+		page.Wd, page.Ht = page.Ht, page.Wd
+	}
+	return page, false, nil
+}
+
+// pdfPageLayout returns the page to use for a diagram of the given size in
+// points and where to paint the diagram on it. Diagrams are scaled down to fit
+// fixed-size pages and centered horizontally. Auto pages are as large as the
+// diagram plus margins.
+func pdfPageLayout(options exportOptions, width, height float64) (
+	page gofpdf.SizeType, scale, x, y float64, err error,
+) {
+	page, auto, err := pdfPageFormat(options)
+	if err != nil {
+		return page, 0, 0, 0, err
+	}
+	if auto {
+		page = gofpdf.SizeType{
+			Wd: width + 2*pdfPageMargin,
+			Ht: height + 2*pdfPageMargin,
+		}
+		return page, 1, pdfPageMargin, pdfPageMargin, nil
+	}
+	availableW := page.Wd - 2*pdfPageMargin
+	availableH := page.Ht - 2*pdfPageMargin
+	scale = math.Min(1, math.Min(availableW/width, availableH/height))
+	x = pdfPageMargin + (availableW-scale*width)/2
+	return page, scale, x, pdfPageMargin, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/gonutz/structorama/parser"
+)
+
+func TestSmallDiagramsAreCenteredOnThePageAtFullSize(t *testing.T) {
+	page, scale, x, y, err := pdfPageLayout(exportOptions{pageSize: "letter"}, 100, 50)
+	check.Eq(t, err, nil)
+	check.Eq(t, page, gofpdf.SizeType{Wd: 612, Ht: 792})
+	check.Eq(t, scale, 1.0)
+	check.Eq(t, x, (612-100)/2.0)
+	check.Eq(t, y, pdfPageMargin)
+}
+
+func TestLargeDiagramsAreScaledDownToFitThePage(t *testing.T) {
+	// The default is A4 in portrait mode.
+	page, scale, x, y, err := pdfPageLayout(exportOptions{}, 2000, 100)
+	check.Eq(t, err, nil)
+	check.Eq(t, page, pdfPageSizes["a4"])
+	check.Eq(t, scale, (595.28-2*pdfPageMargin)/2000)
+	check.Eq(t, x, pdfPageMargin)
+	check.Eq(t, y, pdfPageMargin)
+
+	page, scale, _, _, err = pdfPageLayout(
+		exportOptions{pageSize: "A4", landscape: true},
+		100, 2000,
+	)
+	check.Eq(t, err, nil)
+	check.Eq(t, page, gofpdf.SizeType{Wd: 841.89, Ht: 595.28})
+	check.Eq(t, scale, (595.28-2*pdfPageMargin)/2000)
+}
+
+func TestAutoPageFitsTheDiagram(t *testing.T) {
+	page, scale, x, y, err := pdfPageLayout(
+		exportOptions{pageSize: "auto", landscape: true},
+		2000, 100,
+	)
+	check.Eq(t, err, nil)
+	check.Eq(t, page, gofpdf.SizeType{
+		Wd: 2000 + 2*pdfPageMargin,
+		Ht: 100 + 2*pdfPageMargin,
+	})
+	check.Eq(t, scale, 1.0)
+	check.Eq(t, x, pdfPageMargin)
+	check.Eq(t, y, pdfPageMargin)
+}
+
+func TestUnknownPageSizeIsAnError(t *testing.T) {
+	_, _, _, _, err := pdfPageLayout(exportOptions{pageSize: "A5"}, 1, 1)
+	check.Eq(t, err.Error(), `unknown page size "A5"`)
+}
+
+func TestDiagramsThatFitOnOnePageAreNotSplit(t *testing.T) {
+	p := &mockPainter{lineHeight: 10, textW: 10, textH: 10}
+	s := &parser.Structogram{Statements: []parser.Statement{
+		parser.Instruction{Text: "a"},
+		parser.Instruction{Text: "b"},
+	}}
+	// Each instruction is 20 high, with the line between them and the border
+	// the whole diagram is 20+1+20+2 high.
+	pages := splitPages(p, paintSettings{}, s, 43)
+	check.Eq(t, len(pages), 1)
+	check.Eq(t, pages[0].diagram, s)
+	check.Eq(t, pages[0].continued, false)
+}
+
+func TestTallDiagramsAreSplitAtTopLevelStatements(t *testing.T) {
+	p := &mockPainter{lineHeight: 10, textW: 10, textH: 10}
+	a := parser.Instruction{Text: "a"}
+	b := parser.Instruction{Text: "b"}
+	c := parser.Instruction{Text: "c"}
+	s := &parser.Structogram{
+		Title:      parser.String{Text: "title"},
+		Statements: []parser.Statement{a, b, c},
+	}
+	// Every page reserves 10+5 for the title, 2 for the border and 5+10 for
+	// the continuation marker, leaving 75-32=43 pixels for statements. Two 20
+	// pixel high instructions with a line between them fit.
+	pages := splitPages(p, paintSettings{}, s, 75)
+	check.Eq(t, pages, []pdfPage{
+		{
+			diagram: &parser.Structogram{
+				Title:      parser.String{Text: "title"},
+				Statements: []parser.Statement{a, b},
+			},
+			continued: true,
+		},
+		{
+			diagram: &parser.Structogram{
+				Title:      parser.String{Text: "title (continued)"},
+				Statements: []parser.Statement{c},
+			},
+		},
+	})
+}
+
+func TestStatementsTallerThanAPageGetTheirOwnPage(t *testing.T) {
+	p := &mockPainter{lineHeight: 10, textW: 10, textH: 10}
+	s := &parser.Structogram{Statements: []parser.Statement{
+		parser.Instruction{Text: "a"},
+		parser.Instruction{Text: "b"},
+	}}
+	pages := splitPages(p, paintSettings{}, s, 1)
+	check.Eq(t, len(pages), 2)
+	check.Eq(t, pages[0].diagram.Title.Text, "")
+	check.Eq(t, pages[0].diagram.Statements, s.Statements[:1])
+	check.Eq(t, pages[0].continued, true)
+	check.Eq(t, pages[1].diagram.Title.Text, "(continued)")
+	check.Eq(t, pages[1].diagram.Statements, s.Statements[1:])
+	check.Eq(t, pages[1].continued, false)
+}
@@ -5,11 +5,9 @@ go 1.19
 require (
 	github.com/gonutz/check v1.2.1
 	github.com/gonutz/gofont v1.0.0
+	github.com/gonutz/w32/v2 v2.2.2
 	github.com/gonutz/wui/v2 v2.8.0
 	github.com/jung-kurt/gofpdf v1.16.2
 )
 
-require (
-	github.com/gonutz/fontstash.go v1.0.0 // indirect
-	github.com/gonutz/w32/v2 v2.2.2 // indirect
-)
+require github.com/gonutz/fontstash.go v1.0.0 // indirect
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func runGUI() {
+	fmt.Fprint(os.Stderr, "The GUI is only available on Windows.\n\n"+usage)
+	os.Exit(2)
+}
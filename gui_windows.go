@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/gonutz/w32/v2"
+	"github.com/gonutz/wui/v2"
+
+	"github.com/gonutz/structorama/parser"
+)
+
+func runGUI() {
+	codeFont, _ := wui.NewFont(wui.FontDesc{
+		Name:   "Courier New",
+		Height: -19,
+		Bold:   true,
+	})
+	previewFont, _ := wui.NewFont(wui.FontDesc{
+		Name:   "Tahoma",
+		Height: -17,
+	})
+
+	window := wui.NewWindow()
+	window.SetFont(codeFont)
+	window.SetTitle("Structorama")
+
+	codeEditor := wui.NewTextEdit()
+	codeEditor.SetAnchors(wui.AnchorMinAndCenter, wui.AnchorMinAndMax)
+	codeEditor.SetSize(300, 400)
+	codeEditor.SetWritesTabs(true)
+	window.Add(codeEditor)
+
+	preview := wui.NewPaintBox()
+	// TODO
+	//preview.SetFont(previewFont)
+	preview.SetAnchors(wui.AnchorMaxAndCenter, wui.AnchorMinAndMax)
+	preview.SetX(300)
+	preview.SetSize(300, 400)
+	window.Add(preview)
+
+	window.SetState(wui.WindowMaximized)
+	window.SetOnShow(codeEditor.Focus)
+
+	const example = `title "optional diagram caption"
+
+"counter := 0"
+
+// Line comments and
+/* block comments are not part of the diagram. */
+
+if "only if" {
+}
+
+if "if-else" "T" {
+} else "F" {
+}
+
+if "if" {
+} else if "else-if" {
+} else {
+}
+
+switch "subject" {
+	case "1" {}
+	case "2" {}
+	case default {}
+}
+
+while {
+	"infinite loop"
+}
+
+while "i=0; i<10; i++" {
+	break "early exit the loop"
+}
+
+do {} while "i<10"
+
+repeat {} until "i=10"
+
+for "i" "1" "10" "step 2" {
+	"counting loop, the step is optional"
+}
+
+foreach "item" "in list" {
+	continue "with the next item"
+}
+
+call "some function"
+
+try {
+	call "open file"
+} catch "IOError e" {
+	"one or more catch clauses"
+} finally {
+	"the finally block is optional"
+}
+
+if "done" {
+	return "leave the function"
+}
+
+if "fatal error" {
+	exit "end the program"
+}
+
+parallel {
+	{
+		if "nested things" {
+			"in block 1"
+		}
+	}
+	{}
+	{
+		"block right of the empty block"
+	}
+}`
+
+	// TODO
+	//codeEdit.SetLineBreak("\n"), this should probably be the default in Go.
+	codeEditor.SetText(strings.Replace(example, "\n", "\r\n", -1))
+
+	var lastValidStructogram *parser.Structogram
+	var settings paintSettings
+	preview.SetOnPaint(func(canvas *wui.Canvas) {
+		canvas.SetFont(previewFont)
+		canvas.FillRect(
+			0, 0, canvas.Width(), canvas.Height(),
+			wui.RGB(255, 255, 255),
+		)
+
+		// We keep painting the valid parts of the code while the user is
+		// typing and list all errors below them.
+		s, errs := parser.ParsePartial(codeEditor.Text())
+		if len(errs) == 0 {
+			lastValidStructogram = s
+		}
+
+		paintStructogram(
+			offsetPainter{p: canvasPainter{c: canvas}, dx: 10, dy: 10},
+			settings,
+			s,
+		)
+
+		if len(errs) > 0 {
+			messages := make([]string, len(errs))
+			for i, err := range errs {
+				messages[i] = err.Error()
+			}
+			canvas.TextRectFormat(
+				0, 0, canvas.Width(), canvas.Height(),
+				strings.Join(messages, "\n"),
+				wui.FormatBottomCenter, wui.RGB(255, 0, 0),
+			)
+		}
+	})
+
+	// formatCode formats the selected statements or, if nothing is selected,
+	// all the code. The changes are made like typing so the user can undo
+	// them.
+	formatCode := func() {
+		code := codeEditor.Text()
+		start, end := codeEditor.CursorPosition()
+		var edits []parser.TextEdit
+		var err error
+		if start == end {
+			var formatted string
+			formatted, err = parser.FormatString(code)
+			edits = []parser.TextEdit{{
+				End:     parser.Pos{Offset: len(code)},
+				NewText: formatted,
+			}}
+		} else {
+			edits, err = parser.FormatRange(
+				code,
+				byteOffset(code, start),
+				byteOffset(code, end),
+			)
+		}
+		if err == nil {
+			// Later edits come first so the positions of earlier ones stay
+			// valid.
+			for i := len(edits) - 1; i >= 0; i-- {
+				codeEditor.SetSelection(
+					characterIndex(code, edits[i].Start),
+					characterIndex(code, edits[i].End),
+				)
+				replaceSelection(
+					codeEditor,
+					strings.Replace(edits[i].NewText, "\n", "\r\n", -1),
+				)
+			}
+		} else {
+			wui.MessageBoxError("Formatting Error", err.Error())
+			// Select the offending code so the user can see what to fix.
+			if parseErr, ok := err.(*parser.Error); ok {
+				codeEditor.SetSelection(
+					characterIndex(code, parseErr.Start),
+					characterIndex(code, parseErr.End),
+				)
+				codeEditor.Focus()
+			}
+		}
+	}
+
+	// The PDF menu lets the user choose the page format for exporting.
+	pdfOptions := exportOptions{pageSize: "A4"}
+	pdfMenu := wui.NewMenu("&PDF")
+	var pageSizeItems []*wui.MenuString
+	for _, size := range []struct{ text, pageSize string }{
+		{"A4", "A4"},
+		{"A3", "A3"},
+		{"Letter", "Letter"},
+		{"Fit page to diagram", "auto"},
+	} {
+		size := size
+		item := wui.NewMenuString(size.text)
+		item.SetChecked(size.pageSize == pdfOptions.pageSize)
+		item.SetOnClick(func() {
+			pdfOptions.pageSize = size.pageSize
+			for _, other := range pageSizeItems {
+				other.SetChecked(other == item)
+			}
+		})
+		pageSizeItems = append(pageSizeItems, item)
+		pdfMenu.Add(item)
+	}
+	pdfMenu.Add(wui.NewMenuSeparator())
+	landscapeItem := wui.NewMenuString("Landscape")
+	landscapeItem.SetOnClick(func() {
+		pdfOptions.landscape = !pdfOptions.landscape
+		landscapeItem.SetChecked(pdfOptions.landscape)
+	})
+	pdfMenu.Add(landscapeItem)
+
+	exportPDF := func() {
+		var pdf bytes.Buffer
+		pdfOptions.paint = settings
+		if err := writePDF(&pdf, lastValidStructogram, pdfOptions); err != nil {
+			wui.MessageBoxError("Error exporting PDF", err.Error())
+			return
+		}
+
+		dlg := wui.NewFileSaveDialog()
+		dlg.SetTitle("Select output path")
+		dlg.AddFilter("PDF File", ".pdf")
+		dlg.SetInitialPath("diagram.pdf")
+		if ok, path := dlg.Execute(window); ok {
+			err := os.WriteFile(path, pdf.Bytes(), 0666)
+			if err != nil {
+				wui.MessageBoxError("Error exporting PDF", err.Error())
+			} else {
+				exec.Command("cmd", "/C", path).Start()
+			}
+		}
+	}
+
+	pdfMenu.Add(wui.NewMenuSeparator())
+	pdfMenu.Add(wui.NewMenuString("Export...\tCtrl+E").SetOnClick(exportPDF))
+
+	// The view menu changes how the diagram is painted, in the preview and in
+	// exported PDFs.
+	viewMenu := wui.NewMenu("&View")
+	multiBranchItem := wui.NewMenuString("Else-If Chains as One Decision")
+	multiBranchItem.SetOnClick(func() {
+		settings.multiBranchIfChains = !settings.multiBranchIfChains
+		multiBranchItem.SetChecked(settings.multiBranchIfChains)
+		preview.Paint()
+	})
+	viewMenu.Add(multiBranchItem)
+
+	window.SetMenu(wui.NewMainMenu().Add(pdfMenu).Add(viewMenu))
+
+	codeEditor.SetOnTextChange(preview.Paint)
+
+	window.SetShortcut(formatCode, wui.KeyControl, wui.KeyF)
+	window.SetShortcut(exportPDF, wui.KeyControl, wui.KeyE)
+	window.SetShortcut(window.Close, wui.KeyEscape)
+
+	window.Show()
+}
+
+type canvasPainter struct {
+	c *wui.Canvas
+}
+
+const infinite = 0x0FFFFFFF
+
+func (p canvasPainter) Text(x, y int, s string) {
+	p.c.TextRect(x, y, infinite, infinite, s, wui.RGB(0, 0, 0))
+}
+
+func (p canvasPainter) TextSize(s string) (width, height int) {
+	return p.c.TextRectExtent(s, infinite)
+}
+
+func (p canvasPainter) Rect(x, y, width, height int) {
+	p.c.DrawRect(x, y, width, height, wui.RGB(0, 0, 0))
+}
+
+func (p canvasPainter) Line(x1, y1, x2, y2 int) {
+	p.c.Line(x1, y1, x2, y2, wui.RGB(0, 0, 0))
+	// Draw the last pixel, Canvas.Line does not include it.
+	p.c.Line(x2, y2, x2+1, y2, wui.RGB(0, 0, 0))
+}
+
+func (p canvasPainter) LineHeight() int {
+	_, h := p.c.TextExtent("|")
+	return h
+}
+
+// replaceSelection replaces the selected text in the editor. Unlike setting
+// the whole text, this keeps the undo history and the rest of the text.
+func replaceSelection(editor *wui.TextEdit, text string) {
+	ptr, err := syscall.UTF16PtrFromString(text)
+	if err != nil {
+		return
+	}
+	w32.SendMessage(
+		w32.HWND(editor.Handle()),
+		w32.EM_REPLACESEL,
+		1, // The user can undo the change.
+		uintptr(unsafe.Pointer(ptr)),
+	)
+}
@@ -0,0 +1,245 @@
+// Package lint finds diagrams that parse but do not make sense, like a break
+// outside of any loop or a switch without cases.
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gonutz/structorama/parser"
+)
+
+// The IDs of the rules that Lint checks.
+const (
+	BreakOutsideLoop = "break-outside-loop"
+	UnreachableCode  = "unreachable-code"
+	EmptySwitch      = "empty-switch"
+	DuplicateCase    = "duplicate-case"
+	TrivialParallel  = "trivial-parallel"
+	EmptyBranch      = "empty-branch"
+	EndlessLoop      = "endless-loop"
+)
+
+// Rule describes a rule that Lint checks.
+type Rule struct {
+	ID          string
+	Description string
+}
+
+// Rules are all rules that Lint checks.
+var Rules = []Rule{
+	{BreakOutsideLoop, "break or continue is not inside a loop"},
+	{UnreachableCode, "statements follow a break, continue, return or exit in the same block"},
+	{EmptySwitch, "switch has no cases"},
+	{DuplicateCase, "switch has two cases with the same label or two default cases"},
+	{TrivialParallel, "parallel has less than two blocks"},
+	{EmptyBranch, "branch of an if is empty"},
+	{EndlessLoop, "infinite loop has no break, return or exit"},
+}
+
+// Config enables and disables rules by their ID. Rules that are not in the map
+// are enabled.
+type Config map[string]bool
+
+func (c Config) enabled(rule string) bool {
+	enabled, ok := c[rule]
+	return enabled || !ok
+}
+
+// Warning is a problem found by Lint.
+type Warning struct {
+	Rule       string
+	Message    string
+	Start, End parser.Pos
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", w.Start.Line, w.Start.Col, w.Message, w.Rule)
+}
+
+// Lint checks the structogram with all rules that are enabled in the config.
+// The warnings are sorted by position. A nil config enables all rules.
+func Lint(s *parser.Structogram, config Config) []Warning {
+	l := linter{config: config}
+	// parents are the nodes around the current one.
+	var parents []parser.Node
+	parser.Inspect(s, func(n parser.Node) bool {
+		if n == nil {
+			parents = parents[:len(parents)-1]
+			return false
+		}
+		l.check(n, parents)
+		parents = append(parents, n)
+		return true
+	})
+	sort.SliceStable(l.warnings, func(i, j int) bool {
+		return l.warnings[i].Start.Offset < l.warnings[j].Start.Offset
+	})
+	return l.warnings
+}
+
+type linter struct {
+	config   Config
+	warnings []Warning
+}
+
+func (l *linter) warn(rule string, n parser.Node, format string, args ...interface{}) {
+	l.warnRange(rule, n.Start(), n.End(), format, args...)
+}
+
+func (l *linter) warnRange(rule string, start, end parser.Pos, format string, args ...interface{}) {
+	if l.config.enabled(rule) {
+		l.warnings = append(l.warnings, Warning{
+			Rule:    rule,
+			Message: fmt.Sprintf(format, args...),
+			Start:   start,
+			End:     end,
+		})
+	}
+}
+
+func (l *linter) check(n parser.Node, parents []parser.Node) {
+	switch x := n.(type) {
+	case parser.Break:
+		if !inLoop(parents) {
+			l.warn(BreakOutsideLoop, x, "break outside of a loop")
+		}
+
+	case parser.Continue:
+		if !inLoop(parents) {
+			l.warn(BreakOutsideLoop, x, "continue outside of a loop")
+		}
+
+	case parser.Block:
+		l.checkStatements(x.Statements)
+
+	case *parser.Structogram:
+		l.checkStatements(x.Statements)
+
+	case parser.If:
+		l.checkBranch(x.Then, "if")
+
+	case parser.IfElse:
+		l.checkBranch(x.Then, "if")
+		l.checkBranch(x.Else, "else")
+
+	case parser.IfChain:
+		l.checkBranch(x.Then, "if")
+		for _, e := range x.ElseIfs {
+			l.checkBranch(e.Block, "else-if")
+		}
+		if x.HasElse {
+			l.checkBranch(x.Else, "else")
+		}
+
+	case parser.Switch:
+		if len(x.Cases) == 0 {
+			l.warn(EmptySwitch, x, "switch has no cases")
+		}
+		seen := map[string]bool{}
+		hasDefault := false
+		for _, c := range x.Cases {
+			if c.IsDefault {
+				if hasDefault {
+					l.warnRange(DuplicateCase, c.Start(), c.End(), "duplicate default case")
+				}
+				hasDefault = true
+			} else {
+				if seen[c.Condition.Text] {
+					l.warnRange(DuplicateCase, c.Start(), c.End(), "duplicate case %q", c.Condition.Text)
+				}
+				seen[c.Condition.Text] = true
+			}
+		}
+
+	case parser.Parallel:
+		if len(x.Blocks) == 0 {
+			l.warn(TrivialParallel, x, "parallel has no blocks")
+		} else if len(x.Blocks) == 1 {
+			l.warn(TrivialParallel, x, "parallel has only one block")
+		}
+
+	case parser.InfiniteLoop:
+		if !breaks(x.Block) {
+			l.warn(EndlessLoop, x, "infinite loop has no break, return or exit")
+		}
+	}
+}
+
+// checkStatements reports statements after a jump.
+func (l *linter) checkStatements(statements []parser.Statement) {
+	for i, s := range statements {
+		if jump := jumpKeyword(s); jump != "" && i+1 < len(statements) {
+			l.warnRange(
+				UnreachableCode,
+				statements[i+1].Start(),
+				statements[len(statements)-1].End(),
+				"unreachable code after %s",
+				jump,
+			)
+			return
+		}
+	}
+}
+
+// jumpKeyword returns the keyword of a break, continue, return or exit and ""
+// for all other statements.
+func jumpKeyword(s parser.Statement) string {
+	switch s.(type) {
+	case parser.Break:
+		return "break"
+	case parser.Continue:
+		return "continue"
+	case parser.Return:
+		return "return"
+	case parser.Exit:
+		return "exit"
+	}
+	return ""
+}
+
+func (l *linter) checkBranch(b parser.Block, branch string) {
+	if len(b.Statements) == 0 {
+		l.warn(EmptyBranch, b, "empty %s branch", branch)
+	}
+}
+
+func isLoop(n parser.Node) bool {
+	switch n.(type) {
+	case parser.InfiniteLoop, parser.While, parser.DoWhile, parser.RepeatUntil,
+		parser.For, parser.Foreach:
+		return true
+	}
+	return false
+}
+
+func inLoop(parents []parser.Node) bool {
+	for _, p := range parents {
+		if isLoop(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// breaks tells whether the block has a break that leaves the loop around it,
+// i.e. one that is not inside a nested loop, or a return or exit anywhere.
+func breaks(b parser.Block) bool {
+	found := false
+	var parents []parser.Node
+	parser.Inspect(b, func(n parser.Node) bool {
+		if n == nil {
+			parents = parents[:len(parents)-1]
+			return false
+		}
+		switch n.(type) {
+		case parser.Return, parser.Exit:
+			found = true
+		case parser.Break:
+			found = found || !inLoop(parents)
+		}
+		parents = append(parents, n)
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,137 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+
+	"github.com/gonutz/structorama/parser"
+)
+
+func TestBreakOutsideOfLoop(t *testing.T) {
+	checkWarnings(t, `
+while "x" { if "y" { break "b" } }
+do { switch "z" { case "1" { break "c" } } } while "x"
+for "i" "1" "2" { break "d" } foreach "x" "in xs" { break "e" }
+while "y" { continue "" }
+repeat { break "f" } until "g"
+if "z" { continue "" }
+break "a"
+`,
+		"7:10: continue outside of a loop (break-outside-loop)",
+		"8:1: break outside of a loop (break-outside-loop)",
+	)
+}
+
+func TestUnreachableCodeAfterBreak(t *testing.T) {
+	checkWarnings(t, `while {
+	"a"
+	break ""
+	"b"
+	"c"
+}`, "4:2: unreachable code after break (unreachable-code)")
+}
+
+func TestUnreachableCodeAfterOtherJumps(t *testing.T) {
+	checkWarnings(t, `while "x" {
+	continue ""
+	"a"
+}
+return "x"
+exit ""`,
+		"3:2: unreachable code after continue (unreachable-code)",
+		"6:1: unreachable code after return (unreachable-code)",
+	)
+}
+
+func TestSwitchCases(t *testing.T) {
+	checkWarnings(t, `
+switch "a" {}
+switch "b" {
+	case "1" {}
+	case "2" {}
+	case "1" {}
+	case default {}
+	case default "other" {}
+}`,
+		"2:1: switch has no cases (empty-switch)",
+		`6:2: duplicate case "1" (duplicate-case)`,
+		"8:2: duplicate default case (duplicate-case)",
+	)
+}
+
+func TestParallelNeedsTwoBlocks(t *testing.T) {
+	checkWarnings(t, `
+parallel {}
+parallel { { "a" } }
+parallel { { "a" } { "b" } }`,
+		"2:1: parallel has no blocks (trivial-parallel)",
+		"3:1: parallel has only one block (trivial-parallel)",
+	)
+}
+
+func TestEmptyBranches(t *testing.T) {
+	checkWarnings(t, `
+if "a" {}
+if "b" { "c" } else {}
+if "d" {} else { "e" }
+if "f" { "g" } else if "h" {} else {}`,
+		"2:8: empty if branch (empty-branch)",
+		"3:21: empty else branch (empty-branch)",
+		"4:8: empty if branch (empty-branch)",
+		"5:28: empty else-if branch (empty-branch)",
+		"5:36: empty else branch (empty-branch)",
+	)
+}
+
+func TestInfiniteLoopWithoutBreak(t *testing.T) {
+	checkWarnings(t, `
+while { "a" }
+while { while "b" { break "" } }
+while { if "c" { break "" } }
+while { while "d" { return "" } }
+while { exit "" }`,
+		"2:1: infinite loop has no break, return or exit (endless-loop)",
+		"3:1: infinite loop has no break, return or exit (endless-loop)",
+	)
+}
+
+func TestRulesCanBeDisabled(t *testing.T) {
+	s, err := parser.ParseString(`switch "a" {} parallel {}`)
+	check.Eq(t, err, nil)
+	warnings := Lint(s, Config{EmptySwitch: false, TrivialParallel: true})
+	check.Eq(t, len(warnings), 1)
+	check.Eq(t, warnings[0].Rule, TrivialParallel)
+}
+
+func TestWarningsHaveRanges(t *testing.T) {
+	s, err := parser.ParseString(`break "a" "b"`)
+	check.Eq(t, err, nil)
+	check.Eq(t, Lint(s, nil), []Warning{
+		{
+			Rule:    BreakOutsideLoop,
+			Message: "break outside of a loop",
+			Start:   parser.Pos{Col: 1, Line: 1, Offset: 0},
+			End:     parser.Pos{Col: 10, Line: 1, Offset: 9},
+		},
+		{
+			Rule:    UnreachableCode,
+			Message: "unreachable code after break",
+			Start:   parser.Pos{Col: 11, Line: 1, Offset: 10},
+			End:     parser.Pos{Col: 14, Line: 1, Offset: 13},
+		},
+	})
+}
+
+func checkWarnings(t *testing.T, code string, want ...string) {
+	t.Helper()
+	s, err := parser.ParseString(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, w := range Lint(s, nil) {
+		have = append(have, w.String())
+	}
+	check.Eq(t, have, want)
+}
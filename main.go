@@ -1,18 +1,13 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"image"
 	"image/color"
-	"image/png"
-	"os/exec"
-	"strings"
-
-	"github.com/jung-kurt/gofpdf"
+	"math"
+	"os"
 
 	"github.com/gonutz/gofont"
-	"github.com/gonutz/wui/v2"
 
 	"github.com/gonutz/structorama/parser"
 )
@@ -20,173 +15,13 @@ import (
 // TODO Have a setting for the text for if's true and false cases and for a
 // switch's default case.
 
+// main starts the GUI when called without arguments. With arguments, it runs
+// the command line tool, see usage.
 func main() {
-	codeFont, _ := wui.NewFont(wui.FontDesc{
-		Name:   "Courier New",
-		Height: -19,
-		Bold:   true,
-	})
-	previewFont, _ := wui.NewFont(wui.FontDesc{
-		Name:   "Tahoma",
-		Height: -17,
-	})
-
-	window := wui.NewWindow()
-	window.SetFont(codeFont)
-	window.SetTitle("Structorama")
-
-	codeEditor := wui.NewTextEdit()
-	codeEditor.SetAnchors(wui.AnchorMinAndCenter, wui.AnchorMinAndMax)
-	codeEditor.SetSize(300, 400)
-	codeEditor.SetWritesTabs(true)
-	window.Add(codeEditor)
-
-	preview := wui.NewPaintBox()
-	// TODO
-	//preview.SetFont(previewFont)
-	preview.SetAnchors(wui.AnchorMaxAndCenter, wui.AnchorMinAndMax)
-	preview.SetX(300)
-	preview.SetSize(300, 400)
-	window.Add(preview)
-
-	window.SetState(wui.WindowMaximized)
-	window.SetOnShow(codeEditor.Focus)
-
-	const example = `title "optional diagram caption"
-
-"counter := 0"
-
-if "only if" {
-}
-
-if "if-else" "T" {
-} else "F" {
-}
-
-switch "subject" {
-	case "1" {}
-	case "2" {}
-	case default {}
-}
-
-while {
-	"infinite loop"
-}
-
-while "i=0; i<10; i++" {
-	break "early exit the loop"
-}
-
-do {} while "i<10"
-
-call "some function"
-
-parallel {
-	{
-		if "nested things" {
-			"in block 1"
-		}
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
 	}
-	{}
-	{
-		"block right of the empty block"
-	}
-}`
-
-	// TODO
-	//codeEdit.SetLineBreak("\n"), this should probably be the default in Go.
-	codeEditor.SetText(strings.Replace(example, "\n", "\r\n", -1))
-
-	var lastValidStructogram *parser.Structogram
-	preview.SetOnPaint(func(canvas *wui.Canvas) {
-		canvas.SetFont(previewFont)
-		canvas.FillRect(
-			0, 0, canvas.Width(), canvas.Height(),
-			wui.RGB(255, 255, 255),
-		)
-
-		s, err := parser.ParseString(codeEditor.Text())
-		if err == nil {
-			lastValidStructogram = s
-		}
-
-		if lastValidStructogram != nil {
-			paintStructogram(
-				offsetPainter{p: canvasPainter{c: canvas}, dx: 10, dy: 10},
-				lastValidStructogram,
-			)
-		}
-
-		if err != nil {
-			canvas.TextRectFormat(
-				0, 0, canvas.Width(), canvas.Height(),
-				err.Error(), wui.FormatBottomCenter, wui.RGB(255, 0, 0),
-			)
-		}
-	})
-
-	formatCode := func() {
-		code, err := parser.FormatString(codeEditor.Text())
-		if err == nil {
-			codeEditor.SetText(strings.Replace(code, "\n", "\r\n", -1))
-		} else {
-			wui.MessageBoxError("Formatting Error", err.Error())
-		}
-	}
-
-	exportPDF := func() {
-		// Unfortunately implementing a pdfPainter using the gofpdf library
-		// proved to be difficult. Instead we now just create a pixel-based
-		// image, draw to it and render that into the PDF instead.
-
-		fontPath := "C:/Windows/Fonts/" + previewFont.Desc.Name + ".ttf"
-		font, err := gofont.LoadFromFile(fontPath)
-		if err != nil {
-			wui.MessageBoxError("Cannot load font", err.Error())
-			return
-		}
-		font.HeightInPixels = 20
-
-		// DIN A4 pages are 210 x 297 mm in size,we keep our image at the same
-		// aspect ratio.
-		img := image.NewRGBA(image.Rect(0, 0, 3*210, 3*297))
-		paintStructogram(
-			offsetPainter{p: imagePainter{img: img, font: font}, dx: 10, dy: 10},
-			lastValidStructogram,
-		)
-
-		pdf := gofpdf.New("P", "mm", "A4", "")
-		pdf.AddPage()
-		var buf bytes.Buffer
-		png.Encode(&buf, img)
-		pdf.RegisterImageOptionsReader(
-			"diagram.png",
-			gofpdf.ImageOptions{ImageType: "PNG"},
-			bytes.NewReader(buf.Bytes()),
-		)
-		pdf.Image("diagram.png", 0, 0, 0, 0, false, "", 0, "")
-
-		dlg := wui.NewFileSaveDialog()
-		dlg.SetTitle("Select output path")
-		dlg.AddFilter("PDF File", ".pdf")
-		dlg.SetInitialPath("diagram.pdf")
-		if ok, path := dlg.Execute(window); ok {
-			err := pdf.OutputFileAndClose(path)
-			if err != nil {
-				wui.MessageBoxError("Error exporting PDF", err.Error())
-			} else {
-				exec.Command("cmd", "/C", path).Start()
-			}
-		}
-	}
-
-	codeEditor.SetOnTextChange(preview.Paint)
-
-	window.SetShortcut(formatCode, wui.KeyControl, wui.KeyF)
-	window.SetShortcut(exportPDF, wui.KeyControl, wui.KeyE)
-	window.SetShortcut(window.Close, wui.KeyEscape)
-
-	window.Show()
+	runGUI()
 }
 
 type painter interface {
@@ -204,35 +39,6 @@ type painter interface {
 	LineHeight() int
 }
 
-type canvasPainter struct {
-	c *wui.Canvas
-}
-
-const infinite = 0x0FFFFFFF
-
-func (p canvasPainter) Text(x, y int, s string) {
-	p.c.TextRect(x, y, infinite, infinite, s, wui.RGB(0, 0, 0))
-}
-
-func (p canvasPainter) TextSize(s string) (width, height int) {
-	return p.c.TextRectExtent(s, infinite)
-}
-
-func (p canvasPainter) Rect(x, y, width, height int) {
-	p.c.DrawRect(x, y, width, height, wui.RGB(0, 0, 0))
-}
-
-func (p canvasPainter) Line(x1, y1, x2, y2 int) {
-	p.c.Line(x1, y1, x2, y2, wui.RGB(0, 0, 0))
-	// Draw the last pixel, Canvas.Line does not include it.
-	p.c.Line(x2, y2, x2+1, y2, wui.RGB(0, 0, 0))
-}
-
-func (p canvasPainter) LineHeight() int {
-	_, h := p.c.TextExtent("|")
-	return h
-}
-
 type offsetPainter struct {
 	p  painter
 	dx int
@@ -259,25 +65,58 @@ func (p offsetPainter) LineHeight() int {
 	return p.p.LineHeight()
 }
 
-func paintStructogram(p painter, x *parser.Structogram) {
+// paintSettings are the user's choices of how to paint diagrams. The zero value
+// is the default.
+type paintSettings struct {
+	// multiBranchIfChains makes else-if chains paint as one decision with a
+	// column per branch, like a switch. Otherwise they paint as nested if-else
+	// boxes.
+	multiBranchIfChains bool
+}
+
+func paintStructogram(p painter, settings paintSettings, x *parser.Structogram) {
+	paintStructogramWidth(p, settings, x, 0)
+}
+
+// paintStructogramWidth is like paintStructogram but makes the diagram at least
+// minWidth pixels wide.
+func paintStructogramWidth(p painter, settings paintSettings, x *parser.Structogram, minWidth int) {
 	if x.Title.Text != "" {
 		p.Text(0, 0, x.Title.Text)
 		_, h := p.TextSize(x.Title.Text)
 		p = offsetPainter{p: p, dy: h + 5}
 	}
 	body := parser.Block{Statements: x.Statements}
-	width, height := minSize(p, body)
+	width, height := minSize(p, settings, body)
+	width = max(width, minWidth)
 	p.Rect(-1, -1, width+2, height+2)
-	paintIn(p, body, width, height)
+	paintIn(p, settings, body, width, height)
 }
 
-func paintIn(p painter, node interface{}, width, height int) {
+// structogramSize returns the size of the area that paintStructogram paints
+// into, not including the one pixel border around the diagram.
+func structogramSize(p painter, settings paintSettings, x *parser.Structogram) (width, height int) {
+	width, height = minSize(p, settings, parser.Block{Statements: x.Statements})
+	if x.Title.Text != "" {
+		titleW, titleH := p.TextSize(x.Title.Text)
+		width = max(width, titleW)
+		height += titleH + 5
+	}
+	return width, height
+}
+
+func paintIn(p painter, settings paintSettings, node interface{}, width, height int) {
 	margin := p.LineHeight()
 	switch x := node.(type) {
 
 	case parser.Instruction:
 		p.Text(margin/2, margin/2, x.Text)
 
+	case parser.BadStatement:
+		// Invalid code is shown as is, like an instruction, so the user sees
+		// what was skipped.
+		p.Text(margin/2, margin/2, x.Text)
+
 	case parser.Call:
 		left := margin / 2
 		right := width - 1 - left
@@ -286,14 +125,32 @@ func paintIn(p painter, node interface{}, width, height int) {
 		p.Text(left+1+margin/2, margin/2, x.Text)
 
 	case parser.Break:
-		p.Line(0, (height-1)/2, height/4, 0)
-		p.Line(0, height/2, height/4, height-1)
+		paintJumpArrow(p, 0, height)
 		p.Text(height/4+1+margin/2, margin/2, x.Text)
 
+	case parser.Return:
+		// A return leaves more than the loop, it has a double arrow.
+		paintJumpArrow(p, 0, height)
+		paintJumpArrow(p, height/4+1, height)
+		p.Text(2*(height/4+1)+margin/2, margin/2, x.Text)
+
+	case parser.Exit:
+		// An exit ends the program, its arrow points at a wall.
+		p.Line(0, 0, 0, height-1)
+		paintJumpArrow(p, 2, height)
+		p.Text(2+height/4+1+margin/2, margin/2, x.Text)
+
+	case parser.Continue:
+		// A continue goes on with the next iteration, its arrow points right.
+		right := width - 1
+		p.Line(right, (height-1)/2, right-height/4, 0)
+		p.Line(right, height/2, right-height/4, height-1)
+		p.Text(margin/2, margin/2, x.Text)
+
 	case parser.Block:
 		sizes := make([]size, len(x.Statements))
 		for i := range sizes {
-			sizes[i].width, sizes[i].height = minSize(p, x.Statements[i])
+			sizes[i].width, sizes[i].height = minSize(p, settings, x.Statements[i])
 		}
 		areas := blockPaintAreas(width, height, sizes)
 		for i := range x.Statements {
@@ -303,6 +160,7 @@ func paintIn(p painter, node interface{}, width, height int) {
 			}
 			paintIn(
 				offsetPainter{p: p, dx: areas[i].x, dy: areas[i].y},
+				settings,
 				x.Statements[i],
 				areas[i].width,
 				areas[i].height,
@@ -313,6 +171,7 @@ func paintIn(p painter, node interface{}, width, height int) {
 		area := paintInfiniteLoopLines(p, x, width, height)
 		paintIn(
 			offsetPainter{p: p, dx: area.x, dy: area.y},
+			settings,
 			x.Block,
 			area.width,
 			area.height,
@@ -322,6 +181,7 @@ func paintIn(p painter, node interface{}, width, height int) {
 		area := paintWhileLoop(p, x, width, height)
 		paintIn(
 			offsetPainter{p: p, dx: area.x, dy: area.y},
+			settings,
 			x.Block,
 			area.width,
 			area.height,
@@ -331,20 +191,51 @@ func paintIn(p painter, node interface{}, width, height int) {
 		area := paintDoWhileLoop(p, x, width, height)
 		paintIn(
 			offsetPainter{p: p, dx: area.x, dy: area.y},
+			settings,
 			x.Block,
 			area.width,
 			area.height,
 		)
 
+	case parser.RepeatUntil:
+		paintIn(p, settings, repeatAsDoWhile(x), width, height)
+
+	case parser.IfChain:
+		paintIn(p, settings, paintedIfChain(x, settings), width, height)
+
+	case parser.Try:
+		labels, blocks := trySections(x)
+		sizes := make([]size, len(blocks))
+		for i := range sizes {
+			sizes[i].width, sizes[i].height = minSize(p, settings, blocks[i])
+		}
+		areas := paintTry(p, labels, sizes, width, height)
+		for i := range blocks {
+			paintIn(
+				offsetPainter{p: p, dx: areas[i].x, dy: areas[i].y},
+				settings,
+				blocks[i],
+				areas[i].width,
+				areas[i].height,
+			)
+		}
+
+	case parser.For:
+		paintIn(p, settings, forLoopAsWhile(x), width, height)
+
+	case parser.Foreach:
+		paintIn(p, settings, foreachLoopAsWhile(x), width, height)
+
 	case parser.Parallel:
 		sizes := make([]size, len(x.Blocks))
 		for i := range sizes {
-			sizes[i].width, sizes[i].height = minSize(p, x.Blocks[i])
+			sizes[i].width, sizes[i].height = minSize(p, settings, x.Blocks[i])
 		}
 		areas := paintParallel(p, sizes, width, height)
 		for i, block := range x.Blocks {
 			paintIn(
 				offsetPainter{p: p, dx: areas[i].x, dy: areas[i].y},
+				settings,
 				block,
 				areas[i].width,
 				areas[i].height,
@@ -353,15 +244,15 @@ func paintIn(p painter, node interface{}, width, height int) {
 
 	case parser.If:
 		// An If is the same as an IfElse with an empty Else.
-		paintIn(p, parser.IfElse{
+		paintIn(p, settings, parser.IfElse{
 			Condition: x.Condition,
 			Then:      x.Then,
 			TrueText:  x.TrueText,
 		}, width, height)
 
 	case parser.IfElse:
-		thenW, thenH := minSize(p, x.Then)
-		elseW, elseH := minSize(p, x.Else)
+		thenW, thenH := minSize(p, settings, x.Then)
+		elseW, elseH := minSize(p, settings, x.Else)
 		blockH := max(thenH, elseH)
 		// bottom is for the separating line between the condition at the top
 		// and the blocks below.
@@ -397,17 +288,35 @@ func paintIn(p painter, node interface{}, width, height int) {
 
 		paintIn(
 			offsetPainter{p: p, dy: bottom + 1},
+			settings,
 			x.Then,
 			thenW, blockH,
 		)
 		paintIn(
 			offsetPainter{p: p, dx: thenW + 1, dy: bottom + 1},
+			settings,
 			x.Else,
 			elseW, blockH,
 		)
 
 	case parser.Switch:
-		// TODO
+		cases := switchCasesInPaintOrder(x.Cases)
+		labels := make([]string, len(cases))
+		sizes := make([]size, len(cases))
+		for i, c := range cases {
+			labels[i] = c.Condition.Text
+			sizes[i].width, sizes[i].height = minSize(p, settings, c.Block)
+		}
+		areas := paintSwitch(p, x.Subject.Text, labels, sizes, width, height)
+		for i, c := range cases {
+			paintIn(
+				offsetPainter{p: p, dx: areas[i].x, dy: areas[i].y},
+				settings,
+				c.Block,
+				areas[i].width,
+				areas[i].height,
+			)
+		}
 
 	default:
 		panic("TODO paintIn: unhandled structogram node: " +
@@ -419,7 +328,7 @@ func paintIn(p painter, node interface{}, width, height int) {
 // the given painter. No border around the node is considered. Parent nodes are
 // expeced to take them into account. See the accompanying unit tests for ASCII
 // art and explanation of these sizes.
-func minSize(p painter, node interface{}) (width, height int) {
+func minSize(p painter, settings paintSettings, node interface{}) (width, height int) {
 	margin := p.LineHeight()
 	switch x := node.(type) {
 
@@ -427,6 +336,10 @@ func minSize(p painter, node interface{}) (width, height int) {
 		textW, textH := p.TextSize(x.Text)
 		return textW + margin, textH + margin
 
+	case parser.BadStatement:
+		textW, textH := p.TextSize(x.Text)
+		return textW + margin, textH + margin
+
 	case parser.Call:
 		textW, textH := p.TextSize(x.Text)
 		return 2*margin + 2 + textW, textH + margin
@@ -437,48 +350,86 @@ func minSize(p painter, node interface{}) (width, height int) {
 		width := height/4 + 1 + textW + margin
 		return width, height
 
+	case parser.Return:
+		textW, textH := p.TextSize(x.Text)
+		height := margin + textH
+		width := 2*(height/4+1) + textW + margin
+		return width, height
+
+	case parser.Exit:
+		textW, textH := p.TextSize(x.Text)
+		height := margin + textH
+		width := 2 + height/4 + 1 + textW + margin
+		return width, height
+
+	case parser.Continue:
+		// The arrow is on the right but just as wide as that of a break.
+		return minSize(p, settings, parser.Break{Text: x.Text})
+
 	case parser.Block:
 		sizes := make([]size, len(x.Statements))
 		for i := range sizes {
-			sizes[i].width, sizes[i].height = minSize(p, x.Statements[i])
+			sizes[i].width, sizes[i].height = minSize(p, settings, x.Statements[i])
 		}
 		return minSizeBlock(margin, sizes)
 
 	case parser.InfiniteLoop:
-		blockW, blockH := minSize(p, x.Block)
+		blockW, blockH := minSize(p, settings, x.Block)
 		return minSizeInfiniteLoop(margin, blockW, blockH)
 
 	case parser.While:
 		textW, textH := p.TextSize(x.Condition.Text)
-		blockW, blockH := minSize(p, x.Block)
+		blockW, blockH := minSize(p, settings, x.Block)
 		return minSizeWhile(margin, textW, textH, blockW, blockH)
 
 	case parser.DoWhile:
 		// While and DoWhile have the same size, one has the block up top, the
 		// other one at the bottom.
-		return minSize(p, parser.While{
+		return minSize(p, settings, parser.While{
 			Condition: x.Condition,
 			Block:     x.Block,
 		})
 
+	case parser.RepeatUntil:
+		return minSize(p, settings, repeatAsDoWhile(x))
+
+	case parser.IfChain:
+		return minSize(p, settings, paintedIfChain(x, settings))
+
+	case parser.Try:
+		labels, blocks := trySections(x)
+		labelSizes := make([]size, len(labels))
+		blockSizes := make([]size, len(blocks))
+		for i := range labels {
+			labelSizes[i].width, labelSizes[i].height = p.TextSize(labels[i])
+			blockSizes[i].width, blockSizes[i].height = minSize(p, settings, blocks[i])
+		}
+		return minSizeTry(margin, labelSizes, blockSizes)
+
+	case parser.For:
+		return minSize(p, settings, forLoopAsWhile(x))
+
+	case parser.Foreach:
+		return minSize(p, settings, foreachLoopAsWhile(x))
+
 	case parser.Parallel:
 		sizes := make([]size, len(x.Blocks))
 		for i := range sizes {
-			sizes[i].width, sizes[i].height = minSize(p, x.Blocks[i])
+			sizes[i].width, sizes[i].height = minSize(p, settings, x.Blocks[i])
 		}
 		return minSizeParallel(margin, sizes)
 
 	case parser.If:
 		// An If is the same as an IfElse with an empty Else.
-		return minSize(p, parser.IfElse{
+		return minSize(p, settings, parser.IfElse{
 			Condition: x.Condition,
 			Then:      x.Then,
 			TrueText:  x.TrueText,
 		})
 
 	case parser.IfElse:
-		thenW, thenH := minSize(p, x.Then)
-		elseW, elseH := minSize(p, x.Else)
+		thenW, thenH := minSize(p, settings, x.Then)
+		elseW, elseH := minSize(p, settings, x.Else)
 		textW, textH := p.TextSize(x.Condition.Text)
 		textW += margin / 2
 		textH = max(textH, margin)
@@ -520,7 +471,16 @@ func minSize(p painter, node interface{}) (width, height int) {
 		return totalW, topH + 1 + max(thenH, elseH)
 
 	case parser.Switch:
-		return 100, 30 // TODO
+		cases := switchCasesInPaintOrder(x.Cases)
+		var subject size
+		subject.width, subject.height = p.TextSize(x.Subject.Text)
+		labels := make([]size, len(cases))
+		blocks := make([]size, len(cases))
+		for i, c := range cases {
+			labels[i].width, labels[i].height = p.TextSize(c.Condition.Text)
+			blocks[i].width, blocks[i].height = minSize(p, settings, c.Block)
+		}
+		return minSizeSwitch(margin, subject, labels, blocks)
 
 	default:
 		panic("TODO minSize: unhandled structogram node: " +
@@ -567,6 +527,122 @@ func minSizeWhile(margin, conditionW, conditionH, blockW, blockH int) (width, he
 	return
 }
 
+// switchCasesInPaintOrder returns the cases of a switch with the default cases
+// moved to the right. All other cases keep their order.
+func switchCasesInPaintOrder(cases []parser.SwitchCase) []parser.SwitchCase {
+	ordered := make([]parser.SwitchCase, 0, len(cases))
+	for _, c := range cases {
+		if !c.IsDefault {
+			ordered = append(ordered, c)
+		}
+	}
+	for _, c := range cases {
+		if c.IsDefault {
+			ordered = append(ordered, c)
+		}
+	}
+	return ordered
+}
+
+// minSizeTry returns the size of a try statement with the given section labels
+// and blocks. The sections are stacked inside a frame that is inset by half a
+// margin. Each section has its label above a line and its block below it,
+// sections are separated by a double line.
+func minSizeTry(margin int, labels, blocks []size) (width, height int) {
+	inset := margin / 2
+	innerW := 0
+	innerH := 3 * (len(labels) - 1)
+	for i := range labels {
+		innerW = max(innerW, max(labels[i].width+margin, blocks[i].width))
+		innerH += labels[i].height + margin + 1 + blocks[i].height
+	}
+	return innerW + 2*inset + 2, innerH + 2*inset + 2
+}
+
+func minSizeSwitch(margin int, subject size, labels, blocks []size) (width, height int) {
+	widths, headerH := switchColumnWidths(margin, subject, labels, blocks)
+	width = len(widths) - 1
+	for _, w := range widths {
+		width += w
+	}
+	blockH := margin
+	for _, b := range blocks {
+		blockH = max(blockH, b.height)
+	}
+	// The subject sits on top of the diagonals, the wider the switch, the more
+	// room there is for it.
+	if free := headerH - 1 - subject.height; free > 0 {
+		byText := ((subject.width+margin)*(headerH-1) + free - 1) / free
+		width = max(width, byText)
+	}
+	return width, headerH + 1 + blockH
+}
+
+// switchColumnWidths returns the minimum width of each case column and the
+// height of the switch's header. The header is split by a diagonal going from
+// the top-left corner down to the left edge of the last column and from there
+// back up to the top-right corner. The subject is placed above the diagonals,
+// the case labels at the bottom of their columns, below the diagonals. Columns
+// close to the bottom of the diagonals thus need to be wider to fit their
+// labels.
+func switchColumnWidths(margin int, subject size, labels, blocks []size) (widths []int, headerH int) {
+	if len(labels) == 0 {
+		// An empty switch has a single empty column.
+		return []int{margin}, subject.height + margin
+	}
+
+	labelH := 0
+	for _, l := range labels {
+		labelH = max(labelH, l.height)
+	}
+	headerH = subject.height + labelH + margin
+	diagonalH := float64(headerH - 1)
+
+	widths = make([]int, len(labels))
+	for i := range widths {
+		widths[i] = max(margin, max(blocks[i].width, labels[i].width+margin/2))
+	}
+
+	// covered is the fraction of a column's width under the diagonal that is
+	// too low for the label to fit below it.
+	covered := func(label size) float64 {
+		return float64(label.height+margin/4) / diagonalH
+	}
+
+	// The last label is right-aligned under the diagonal going up to the
+	// top-right corner.
+	last := len(widths) - 1
+	if c := covered(labels[last]); c < 1 && labels[last] != (size{}) {
+		minW := math.Ceil(float64(labels[last].width+margin/4) / (1 - c))
+		widths[last] = max(widths[last], int(minW))
+	}
+
+	// All other labels are left-aligned under the diagonal coming down from
+	// the top-left corner. Widening a column moves the bottom of the diagonal
+	// to the right, which only gives the labels left of it more room. This is
+	// why a single pass from left to right suffices.
+	for i := 0; i < last; i++ {
+		c := covered(labels[i])
+		if c >= 1 || labels[i] == (size{}) {
+			continue
+		}
+		x, apex := 0, -1
+		for j := 0; j < last; j++ {
+			if j < i {
+				x += widths[j] + 1
+			}
+			apex += widths[j] + 1
+		}
+		labelRight := x + margin/4 + labels[i].width
+		missing := c*float64(apex) - float64(apex-labelRight)
+		if missing > 0 {
+			widths[i] += int(math.Ceil(missing / (1 - c)))
+		}
+	}
+
+	return widths, headerH
+}
+
 func blockPaintAreas(width, height int, blockSizes []size) []rectangle {
 	r := make([]rectangle, len(blockSizes))
 	y := 0
@@ -615,6 +691,138 @@ func paintWhileLoop(p painter, while parser.While, width, height int) (blockArea
 	}
 }
 
+// paintedIfChain returns the statement that the else-if chain is painted as,
+// see paintSettings.
+func paintedIfChain(c parser.IfChain, settings paintSettings) parser.Statement {
+	if settings.multiBranchIfChains {
+		return ifChainAsSwitch(c)
+	}
+	return ifChainAsNestedIfs(c)
+}
+
+// ifChainAsNestedIfs returns the chain as if-else statements where each else
+// has the rest of the chain in it.
+func ifChainAsNestedIfs(c parser.IfChain) parser.Statement {
+	branches := []parser.ElseIf{{
+		Condition: c.Condition,
+		TrueText:  c.TrueText,
+		Block:     c.Then,
+	}}
+	branches = append(branches, c.ElseIfs...)
+
+	var rest parser.Statement
+	for i := len(branches) - 1; i >= 0; i-- {
+		b := branches[i]
+		if rest == nil && !c.HasElse {
+			rest = parser.If{Condition: b.Condition, TrueText: b.TrueText, Then: b.Block}
+			continue
+		}
+		falseText, els := c.FalseText, c.Else
+		if rest != nil {
+			falseText = parser.String{}
+			els = parser.Block{Statements: []parser.Statement{rest}}
+		}
+		rest = parser.IfElse{
+			Condition: b.Condition,
+			TrueText:  b.TrueText,
+			Then:      b.Block,
+			FalseText: falseText,
+			Else:      els,
+		}
+	}
+	return rest
+}
+
+// ifChainAsSwitch returns the chain as a switch without a subject that has a
+// case for each condition. The final else becomes the default case. Branch
+// labels are painted in a line below their conditions.
+func ifChainAsSwitch(c parser.IfChain) parser.Switch {
+	s := parser.Switch{Cases: []parser.SwitchCase{{
+		Condition: labeledCondition(c.Condition, c.TrueText),
+		Block:     c.Then,
+	}}}
+	for _, e := range c.ElseIfs {
+		s.Cases = append(s.Cases, parser.SwitchCase{
+			Condition: labeledCondition(e.Condition, e.TrueText),
+			Block:     e.Block,
+		})
+	}
+	if c.HasElse {
+		label := c.FalseText
+		if label.Text == "" {
+			label = parser.String{Text: "else"}
+		}
+		s.Cases = append(s.Cases, parser.SwitchCase{
+			IsDefault: true,
+			Condition: label,
+			Block:     c.Else,
+		})
+	}
+	return s
+}
+
+// labeledCondition returns the condition with the label of its branch in the
+// line below it, if there is a label.
+func labeledCondition(condition, label parser.String) parser.String {
+	if label.Text == "" {
+		return condition
+	}
+	return parser.String{Text: condition.Text + "\n" + label.Text}
+}
+
+// repeatAsDoWhile returns a do-while loop that looks like the repeat-until
+// loop. Both have their condition at the bottom, the repeat-until loop says
+// "until" to make clear that it stops once the condition is true.
+func repeatAsDoWhile(r parser.RepeatUntil) parser.DoWhile {
+	return parser.DoWhile{
+		Block:     r.Block,
+		Condition: parser.String{Text: "until " + r.Condition.Text},
+	}
+}
+
+// trySections returns the labels and blocks of the try, catch and finally
+// sections of the try statement, from top to bottom.
+func trySections(t parser.Try) (labels []string, blocks []parser.Block) {
+	labels = append(labels, "try")
+	blocks = append(blocks, t.Block)
+	for _, c := range t.Catches {
+		label := "catch"
+		if c.Exception.Text != "" {
+			label += " " + c.Exception.Text
+		}
+		labels = append(labels, label)
+		blocks = append(blocks, c.Block)
+	}
+	if t.HasFinally {
+		labels = append(labels, "finally")
+		blocks = append(blocks, t.Finally)
+	}
+	return labels, blocks
+}
+
+// forLoopAsWhile returns a while loop that looks like the for loop. Counting
+// loops are drawn like while loops, with the loop header as the condition.
+func forLoopAsWhile(f parser.For) parser.While {
+	header := "for " + f.Variable.Text + " = " + f.From.Text + " to " + f.To.Text
+	if f.Step.Text != "" {
+		header += " " + f.Step.Text
+	}
+	return parser.While{Condition: parser.String{Text: header}, Block: f.Block}
+}
+
+// foreachLoopAsWhile returns a while loop that looks like the foreach loop.
+func foreachLoopAsWhile(f parser.Foreach) parser.While {
+	header := "for each " + f.Variable.Text + " " + f.Collection.Text
+	return parser.While{Condition: parser.String{Text: header}, Block: f.Block}
+}
+
+// paintJumpArrow paints an arrow pointing left with its tip at x. The arrow
+// spans the whole height and is height/4 wide.
+func paintJumpArrow(p painter, x, height int) {
+	p.Line(x, (height-1)/2, x+height/4, 0)
+	p.Line(x, height/2, x+height/4, height-1)
+}
+
 func paintDoWhileLoop(p painter, do parser.DoWhile, width, height int) (blockArea rectangle) {
 	margin := p.LineHeight()
 	_, textH := p.TextSize(do.Condition.Text)
@@ -662,6 +870,125 @@ func paintParallel(p painter, blockSizes []size, width, height int) []rectangle
 	return areas
 }
 
+// paintTry paints the frame, section labels and separators of a try statement,
+// see minSizeTry. It returns the areas for the section blocks, from top to
+// bottom. The try block gets any extra height.
+func paintTry(p painter, labels []string, blockSizes []size, width, height int) []rectangle {
+	margin := p.LineHeight()
+	inset := margin / 2
+	p.Rect(inset, inset, width-2*inset, height-2*inset)
+	left, right := inset+1, width-inset-2
+
+	textHeights := make([]int, len(labels))
+	extraH := height - 2*inset - 2 - 3*(len(labels)-1)
+	for i := range labels {
+		_, textHeights[i] = p.TextSize(labels[i])
+		extraH -= textHeights[i] + margin + 1 + blockSizes[i].height
+	}
+
+	areas := make([]rectangle, len(labels))
+	y := inset + 1
+	for i, label := range labels {
+		if i > 0 {
+			p.Line(left, y, right, y)
+			p.Line(left, y+2, right, y+2)
+			y += 3
+		}
+		p.Text(left+margin/2, y+margin/2, label)
+		y += textHeights[i] + margin
+		p.Line(left, y, right, y)
+		y++
+		areas[i] = rectangle{
+			x:      left,
+			y:      y,
+			width:  right - left + 1,
+			height: blockSizes[i].height,
+		}
+		if i == 0 {
+			areas[i].height += extraH
+		}
+		y += areas[i].height
+	}
+	return areas
+}
+
+// paintSwitch paints the header of a switch statement with the subject and the
+// case labels and the lines separating the cases. It returns the areas for the
+// case blocks, from left to right. The labels and blockSizes are expected in
+// paint order, see switchCasesInPaintOrder.
+func paintSwitch(p painter, subject string, labels []string, blockSizes []size, width, height int) []rectangle {
+	margin := p.LineHeight()
+	var subjectSize size
+	subjectSize.width, subjectSize.height = p.TextSize(subject)
+	labelSizes := make([]size, len(labels))
+	for i := range labels {
+		labelSizes[i].width, labelSizes[i].height = p.TextSize(labels[i])
+	}
+	minWidths, _ := switchColumnWidths(margin, subjectSize, labelSizes, blockSizes)
+
+	blockH := margin
+	for _, s := range blockSizes {
+		blockH = max(blockH, s.height)
+	}
+	// bottom is the line separating the header from the case blocks.
+	bottom := height - blockH - 1
+
+	// Distribute the available width over the columns in the ratio of their
+	// minimum widths.
+	totalMinW := 0
+	for _, w := range minWidths {
+		totalMinW += w
+	}
+	scale := float64(width-(len(minWidths)-1)) / float64(totalMinW)
+	last := len(minWidths) - 1
+	areas := make([]rectangle, len(minWidths))
+	x := 0
+	for i := range areas {
+		areas[i].x = x
+		areas[i].y = bottom + 1
+		areas[i].width = int(float64(minWidths[i])*scale + 0.5)
+		if i == last {
+			areas[i].width = width - x
+		}
+		areas[i].height = blockH
+		x += 1 + areas[i].width
+	}
+
+	// apex is where the two diagonals meet at the bottom of the header.
+	apex := max(0, areas[last].x-1)
+	p.Line(0, bottom, width-1, bottom)
+	if apex > 0 {
+		p.Line(0, 0, apex, bottom-1)
+	}
+	p.Line(apex, bottom-1, width-1, 0)
+	for _, a := range areas[1:] {
+		// The lines between the columns start at the diagonal.
+		x := a.x - 1
+		top := bottom - 1
+		if x < apex {
+			top = int(float64((bottom-1)*x)/float64(apex) + 0.5)
+		}
+		p.Line(x, top, x, height-1)
+	}
+
+	// Place the subject at the same relative position as the apex, like the
+	// condition of an IfElse.
+	textW := subjectSize.width + margin/2
+	apexRatio := float64(apex) / float64(max(1, width-1))
+	textX := int(float64(width-textW)*apexRatio + 0.5)
+	p.Text(textX+margin/4, 0, subject)
+
+	for i := range labels {
+		x := areas[i].x + margin/4
+		if i == last {
+			x = width - labelSizes[i].width - margin/4
+		}
+		p.Text(x, bottom-labelSizes[i].height-margin/4, labels[i])
+	}
+
+	return areas[:len(labels)]
+}
+
 type rectangle struct {
 	x, y, width, height int
 }
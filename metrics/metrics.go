@@ -0,0 +1,74 @@
+// Package metrics computes numbers about the size and complexity of
+// structograms, e.g. to find procedures that are too complex.
+package metrics
+
+import "github.com/gonutz/structorama/parser"
+
+// Metrics are the numbers about a structogram.
+type Metrics struct {
+	// Statements is the number of statements, including nested ones.
+	Statements int `json:"statements"`
+	// MaxDepth is how deeply statements are nested. Top-level statements have
+	// depth 1, statements in their blocks depth 2 and so on.
+	MaxDepth int `json:"maxDepth"`
+	// Complexity is the cyclomatic complexity, one plus the number of
+	// decisions. Every if and loop is one decision, a switch makes one less
+	// decision than it has cases.
+	Complexity int `json:"complexity"`
+	Breaks     int `json:"breaks"`
+	Calls      int `json:"calls"`
+}
+
+// Compute returns the metrics of the structogram.
+func Compute(s *parser.Structogram) Metrics {
+	m := Metrics{Complexity: 1}
+	depth := 0
+	// statementDepths has an entry for every node that is being visited, true
+	// for statements, so we know by how much to decrease depth.
+	var statementDepths []bool
+	parser.Inspect(s, func(n parser.Node) bool {
+		if n == nil {
+			if statementDepths[len(statementDepths)-1] {
+				depth--
+			}
+			statementDepths = statementDepths[:len(statementDepths)-1]
+			return false
+		}
+
+		isStatement := true
+		switch x := n.(type) {
+		case parser.Break:
+			m.Breaks++
+		case parser.Call:
+			m.Calls++
+		case parser.If, parser.IfElse, parser.While, parser.DoWhile, parser.InfiniteLoop,
+			parser.RepeatUntil, parser.For, parser.Foreach:
+			m.Complexity++
+		case parser.Switch:
+			if len(x.Cases) > 1 {
+				m.Complexity += len(x.Cases) - 1
+			}
+		case parser.IfChain:
+			m.Complexity += 1 + len(x.ElseIfs)
+		case parser.Try:
+			// Every catch is another way through the try.
+			m.Complexity += len(x.Catches)
+		case parser.Instruction, parser.Parallel, parser.BadStatement,
+			parser.Return, parser.Continue, parser.Exit:
+			// These statements make no decisions.
+		default:
+			isStatement = false
+		}
+
+		if isStatement {
+			m.Statements++
+			depth++
+			if depth > m.MaxDepth {
+				m.MaxDepth = depth
+			}
+		}
+		statementDepths = append(statementDepths, isStatement)
+		return true
+	})
+	return m
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+
+	"github.com/gonutz/structorama/parser"
+)
+
+func TestEmptyStructogram(t *testing.T) {
+	check.Eq(t, Compute(&parser.Structogram{}), Metrics{Complexity: 1})
+}
+
+func TestStatementsAreCounted(t *testing.T) {
+	s, err := parser.ParseString(`
+"a"
+call "b"
+while {
+	if "c" {
+		call "d"
+		break ""
+	}
+}
+parallel { { "e" } { "f" } }`)
+	check.Eq(t, err, nil)
+	check.Eq(t, Compute(s), Metrics{
+		Statements: 9,
+		MaxDepth:   3,
+		Complexity: 3,
+		Breaks:     1,
+		Calls:      2,
+	})
+}
+
+func TestComplexityCountsDecisions(t *testing.T) {
+	s, err := parser.ParseString(`
+if "a" {} else {}
+switch "b" {
+	case "1" {}
+	case "2" {}
+	case default {}
+}
+switch "c" { case "1" {} }
+switch "d" {}
+while "e" { do {} while "f" }
+for "i" "1" "2" {}
+foreach "x" "in xs" {}
+try {} catch "a" {} catch "b" {} finally {}
+repeat {} until "g"
+if "h" {} else if "i" {} else if "j" {} else {}`)
+	check.Eq(t, err, nil)
+	m := Compute(s)
+	// 1 + if + 2 for the 3-case switch + while + do-while + for + foreach + 2
+	// catches + repeat-until + 3 for the if chain.
+	check.Eq(t, m.Complexity, 14)
+	check.Eq(t, m.MaxDepth, 2)
+}
+
+func TestBuiltStructogramsHaveMetrics(t *testing.T) {
+	s := parser.NewBuilder("").
+		If("a", func(b *parser.Builder) {
+			b.If("b", func(b *parser.Builder) { b.Instr("c") })
+		}).
+		Structogram()
+	check.Eq(t, Compute(s), Metrics{Statements: 3, MaxDepth: 3, Complexity: 3})
+}
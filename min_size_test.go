@@ -31,7 +31,7 @@ func TestInstructionHasTextSizePlusMargins(t *testing.T) {
 
 func checkMinSize(t *testing.T, p painter, x interface{}, wantW, wantH int) {
 	t.Helper()
-	w, h := minSize(p, x)
+	w, h := minSize(p, paintSettings{}, x)
 	check.Eq(t, w, wantW, "width")
 	check.Eq(t, h, wantH, "height")
 }
@@ -88,6 +88,14 @@ func TestBreakHasFourthOfHeightWideArrowOnTheLeft(t *testing.T) {
 	)
 }
 
+func TestJumpsHaveArrowsLikeBreak(t *testing.T) {
+	// Box height is 8+20+8=36 so an arrow is 36/4=9 wide.
+	p := &mockPainter{lineHeight: 16, textW: 40, textH: 20}
+	checkMinSize(t, p, parser.Return{Text: "return"}, 2*(9+1)+8+40+8, 8+20+8)
+	checkMinSize(t, p, parser.Exit{Text: "exit"}, 2+9+1+8+40+8, 8+20+8)
+	checkMinSize(t, p, parser.Continue{Text: "continue"}, 9+1+8+40+8, 8+20+8)
+}
+
 func TestBlockWidthIsMaxOfPartsHeightIsSumOfParts(t *testing.T) {
 	w, h := minSizeBlock(10, []size{{50, 20}, {100, 30}, {40, 40}})
 	check.Eq(t, w, 100)
@@ -184,3 +192,53 @@ func TestParallelIsSumOfBlocksWideAndMaxBlockHigh(t *testing.T) {
 	check.Eq(t, w, 50+1+100+1+20)
 	check.Eq(t, h, 10+1+40+1+10)
 }
+
+func TestSwitchColumnsAreBlocksSideBySide(t *testing.T) {
+	// 	 ________________________
+	// 	|\        subject   /    |
+	// 	|  \_____          /     |
+	// 	| 1  |  2 \____   / def  |
+	// 	|____|_________\_/_______|
+	// 	|    |         |         |
+	// 	|____|_________|_________|
+	//
+	// Cases are laid out next to each other, separated by one pixel wide
+	// lines. The header is high enough for the subject on top of the labels
+	// plus the margin. Without labels, the columns are as wide as their blocks
+	// and the block area is as high as the highest block.
+	w, h := minSizeSwitch(10, size{}, []size{{}, {}}, []size{{50, 20}, {30, 40}})
+	check.Eq(t, w, 50+1+30)
+	check.Eq(t, h, 10+1+40)
+
+	// Every column is at least margin wide and high.
+	w, h = minSizeSwitch(10, size{}, []size{{}, {}}, []size{{1, 1}, {2, 2}})
+	check.Eq(t, w, 10+1+10)
+	check.Eq(t, h, 10+1+10)
+}
+
+func TestEmptySwitchHasOneEmptyColumn(t *testing.T) {
+	w, h := minSizeSwitch(10, size{}, nil, nil)
+	check.Eq(t, w, 10)
+	check.Eq(t, h, 10+1+10)
+}
+
+func TestSwitchLabelsMustFitUnderTheDiagonals(t *testing.T) {
+	// The header is 10+8=18 pixels high, the diagonals go down 17 pixels. Each
+	// label needs 10+8/4=12 of these 17 pixels below the diagonal. The last
+	// label is right-aligned, its column must be wide enough so that the
+	// diagonal going up to the right is higher than the label at the label's
+	// left edge. The first label is left-aligned and its column is widened so
+	// that the diagonal is higher than the label at the label's right edge.
+	w, h := minSizeSwitch(8, size{}, []size{{10, 10}, {10, 10}}, []size{{}, {}})
+	check.Eq(t, w, 41+1+41)
+	check.Eq(t, h, 10+8+1+8)
+}
+
+func TestLongSwitchSubjectDominatesWidth(t *testing.T) {
+	// The 100x10 subject has to fit above the diagonals in the 10+10=20 pixel
+	// high header, the diagonals go down 19 pixels. The switch has to be wide
+	// enough for the subject to fit into a triangle of 9 pixels height.
+	w, h := minSizeSwitch(10, size{100, 10}, []size{{}}, []size{{50, 20}})
+	check.Eq(t, w, (110*19+8)/9)
+	check.Eq(t, h, 10+10+1+20)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/gonutz/check"
@@ -14,17 +15,26 @@ func TestPaintInstruction(t *testing.T) {
 	// 	|  Instruction  |
 	// 	|_______________|
 	p := &mockPainter{lineHeight: 10}
-	paintIn(p, parser.Instruction{Text: "instruction"}, 0, 0)
+	paintIn(p, paintSettings{}, parser.Instruction{Text: "instruction"}, 0, 0)
 	p.checkPainting(t, `Text(5, 5, "instruction")`)
 }
 
+func TestPaintBadStatementLikeInstruction(t *testing.T) {
+	p := &mockPainter{lineHeight: 10, textW: 30, textH: 10}
+	s, _ := parser.ParsePartial(`whlie {}`)
+	bad := s.Statements[0]
+	paintIn(p, paintSettings{}, bad, 0, 0)
+	p.checkPainting(t, `Text(5, 5, "whlie {}")`)
+	checkMinSize(t, p, bad, 5+30+5, 5+10+5)
+}
+
 func TestPaintCall(t *testing.T) {
 	// 	 __________
 	// 	| |      | |
 	// 	| | Call | |
 	// 	|_|______|_|
 	p := &mockPainter{lineHeight: 10}
-	paintIn(p, parser.Call{Text: "call"}, 100, 50)
+	paintIn(p, paintSettings{}, parser.Call{Text: "call"}, 100, 50)
 	p.checkPainting(t,
 		`Line(5, 0, 5, 49)`,
 		`Line(94, 0, 94, 49)`,
@@ -58,7 +68,7 @@ func TestPaintBreak(t *testing.T) {
 	// 	.x........
 	// 	..x.......
 	p := &mockPainter{lineHeight: 10}
-	paintIn(p, parser.Break{Text: "break"}, 100, 40)
+	paintIn(p, paintSettings{}, parser.Break{Text: "break"}, 100, 40)
 	p.checkPainting(t,
 		`Line(0, 19, 10, 0)`,
 		`Line(0, 20, 10, 39)`,
@@ -66,7 +76,7 @@ func TestPaintBreak(t *testing.T) {
 	)
 
 	p = &mockPainter{lineHeight: 10}
-	paintIn(p, parser.Break{Text: "break"}, 100, 41)
+	paintIn(p, paintSettings{}, parser.Break{Text: "break"}, 100, 41)
 	p.checkPainting(t,
 		`Line(0, 20, 10, 0)`,
 		`Line(0, 20, 10, 40)`,
@@ -74,9 +84,43 @@ func TestPaintBreak(t *testing.T) {
 	)
 }
 
+func TestPaintReturnExitAndContinue(t *testing.T) {
+	// 	 ______________    ______________    ______________
+	// 	| / /          |  || /           |  |            \ |
+	// 	|/ /  Return   |  ||/   Exit     |  | Continue    \|
+	// 	|\ \           |  ||\            |  |             /|
+	// 	|_\_\__________|  ||_\___________|  |____________/_|
+	p := &mockPainter{lineHeight: 10}
+	paintIn(p, paintSettings{}, parser.Return{Text: "return"}, 100, 40)
+	p.checkPainting(t,
+		`Line(0, 19, 10, 0)`,
+		`Line(0, 20, 10, 39)`,
+		`Line(11, 19, 21, 0)`,
+		`Line(11, 20, 21, 39)`,
+		`Text(27, 5, "return")`,
+	)
+
+	p = &mockPainter{lineHeight: 10}
+	paintIn(p, paintSettings{}, parser.Exit{Text: "exit"}, 100, 40)
+	p.checkPainting(t,
+		`Line(0, 0, 0, 39)`,
+		`Line(2, 19, 12, 0)`,
+		`Line(2, 20, 12, 39)`,
+		`Text(18, 5, "exit")`,
+	)
+
+	p = &mockPainter{lineHeight: 10}
+	paintIn(p, paintSettings{}, parser.Continue{Text: "continue"}, 100, 40)
+	p.checkPainting(t,
+		`Line(99, 19, 89, 0)`,
+		`Line(99, 20, 89, 39)`,
+		`Text(5, 5, "continue")`,
+	)
+}
+
 func TestPaintingEmptyBlockDoesNothing(t *testing.T) {
 	p := &mockPainter{lineHeight: 10}
-	paintIn(p, parser.Block{}, 100, 40)
+	paintIn(p, paintSettings{}, parser.Block{}, 100, 40)
 	p.checkPainting(t)
 }
 
@@ -134,6 +178,76 @@ func TestPaintingWhileLoop(t *testing.T) {
 	check.Eq(t, area, rectangle{11, 31, 189, 69})
 }
 
+func TestForLoopsArePaintedLikeWhileLoopsWithTheirHeader(t *testing.T) {
+	s, err := parser.ParseString(`for "i" "1" "10" "step 2" { "a" }
+for "j" "1" "3" {}
+foreach "x" "in xs" {}`)
+	check.Eq(t, err, nil)
+	p := &mockPainter{lineHeight: 10, textW: 50, textH: 20}
+	paintIn(p, paintSettings{}, s.Statements[0], 200, 100)
+	check.Eq(t, p.ops[0], `Text(5, 5, "for i = 1 to 10 step 2")`)
+
+	check.Eq(t,
+		forLoopAsWhile(s.Statements[1].(parser.For)).Condition.Text,
+		"for j = 1 to 3",
+	)
+	check.Eq(t,
+		foreachLoopAsWhile(s.Statements[2].(parser.Foreach)).Condition.Text,
+		"for each x in xs",
+	)
+}
+
+func TestPaintingTry(t *testing.T) {
+	// 	 ______________
+	// 	|  __________  |
+	// 	| | try      | |
+	// 	| |__________| |
+	// 	| |          | |
+	// 	| |__________| |
+	// 	| |__________| |
+	// 	| | catch E  | |
+	// 	| |__________| |
+	// 	| |__________| |
+	// 	|______________|
+	//
+	// The frame is inset by half the line height. Every section has a label
+	// above a line and its block below it, sections are separated by a double
+	// line. The try block gets the extra height.
+	p := &mockPainter{lineHeight: 10, textW: 50, textH: 20}
+	areas := paintTry(p, []string{"try", "catch E"}, []size{{20, 10}, {20, 10}}, 200, 120)
+	p.checkPainting(t,
+		`Rect(5, 5, 190, 110)`,
+		`Text(11, 11, "try")`,
+		`Line(6, 36, 193, 36)`,
+		`Line(6, 70, 193, 70)`,
+		`Line(6, 72, 193, 72)`,
+		`Text(11, 78, "catch E")`,
+		`Line(6, 103, 193, 103)`,
+	)
+	check.Eq(t, areas, []rectangle{
+		{x: 6, y: 37, width: 188, height: 33},
+		{x: 6, y: 104, width: 188, height: 10},
+	})
+
+	w, h := minSizeTry(10, []size{{50, 20}, {70, 20}}, []size{{20, 10}, {100, 10}})
+	check.Eq(t, w, 5+1+100+1+5)
+	check.Eq(t, h, 5+1+(20+10+1+10)+3+(20+10+1+10)+1+5)
+}
+
+func TestTryIsPaintedInItsSections(t *testing.T) {
+	s, err := parser.ParseString(`try { "a" } catch "E" {} catch {} finally {}`)
+	check.Eq(t, err, nil)
+	labels, blocks := trySections(s.Statements[0].(parser.Try))
+	check.Eq(t, labels, []string{"try", "catch E", "catch", "finally"})
+	check.Eq(t, len(blocks), 4)
+
+	p := &mockPainter{lineHeight: 10, textW: 50, textH: 20}
+	w, h := minSize(p, paintSettings{}, s.Statements[0])
+	paintIn(p, paintSettings{}, s.Statements[0], w, h)
+	check.Eq(t, p.ops[0], fmt.Sprintf("Rect(5, 5, %d, %d)", w-10, h-10))
+	check.Eq(t, p.ops[len(p.ops)-1], `Text(11, 42, "a")`)
+}
+
 func TestPaintingDoWhileLoop(t *testing.T) {
 	// 	 __________________
 	// 	|  |               |
@@ -153,6 +267,55 @@ func TestPaintingDoWhileLoop(t *testing.T) {
 	check.Eq(t, area, rectangle{11, 0, 189, 69})
 }
 
+func TestIfChainsArePaintedAsNestedIfsOrAsOneDecision(t *testing.T) {
+	s, err := parser.ParseString(`
+if "a" { "1" } else if "b" { "2" } else { "3" }
+if "c" { "4" } else if "d" "T" { "5" }`)
+	check.Eq(t, err, nil)
+	withElse := s.Statements[0].(parser.IfChain)
+	withoutElse := s.Statements[1].(parser.IfChain)
+
+	nested, err := parser.ParseString(`
+if "a" { "1" } else { if "b" { "2" } else { "3" } }
+if "c" { "4" } else { if "d" "T" { "5" } }`)
+	check.Eq(t, err, nil)
+	checkSamePainting(t, paintSettings{}, withElse, nested.Statements[0])
+	checkSamePainting(t, paintSettings{}, withoutElse, nested.Statements[1])
+
+	switched, err := parser.ParseString(`
+switch "" { case "a" { "1" } case "b" { "2" } case default "else" { "3" } }
+switch "" { case "c" { "4" } case "d\nT" { "5" } }`)
+	check.Eq(t, err, nil)
+	multiBranch := paintSettings{multiBranchIfChains: true}
+	checkSamePainting(t, multiBranch, withElse, switched.Statements[0])
+	checkSamePainting(t, multiBranch, withoutElse, switched.Statements[1])
+}
+
+// checkSamePainting checks that both nodes paint the same with the given
+// settings.
+func checkSamePainting(t *testing.T, settings paintSettings, a, b parser.Node) {
+	t.Helper()
+	aPainter := &mockPainter{lineHeight: 10, textW: 20, textH: 10}
+	aW, aH := minSize(aPainter, settings, a)
+	paintIn(aPainter, settings, a, aW, aH)
+	bPainter := &mockPainter{lineHeight: 10, textW: 20, textH: 10}
+	bW, bH := minSize(bPainter, settings, b)
+	paintIn(bPainter, settings, b, bW, bH)
+	check.Eq(t, aPainter.ops, bPainter.ops)
+}
+
+func TestRepeatUntilIsPaintedLikeDoWhileWithUntil(t *testing.T) {
+	s, err := parser.ParseString(`repeat { "a" } until "done"`)
+	check.Eq(t, err, nil)
+	p := &mockPainter{lineHeight: 10, textW: 50, textH: 20}
+	paintIn(p, paintSettings{}, s.Statements[0], 200, 100)
+	check.Eq(t, p.ops[:3], []string{
+		`Line(10, 0, 10, 69)`,
+		`Line(10, 69, 199, 69)`,
+		`Text(5, 75, "until done")`,
+	})
+}
+
 func TestParallelPaintsLinesBetweenBlocks(t *testing.T) {
 	p := &mockPainter{lineHeight: 10}
 	areas := paintParallel(p, nil, 200, 100)
@@ -200,3 +363,76 @@ func TestParallelPaintsLinesBetweenBlocks(t *testing.T) {
 		{51, 11, 150, 78},
 	})
 }
+
+func TestPaintingSwitch(t *testing.T) {
+	// 	 __________________
+	// 	|\__   subject  /  |
+	// 	| 1 |\__     /  def|
+	// 	|___|_2_\___/______|
+	// 	|   |       |      |
+	// 	|___|_______|______|
+	//
+	// A diagonal goes from the top-left corner down to the left edge of the
+	// last column and from there back up to the top-right corner. The lines
+	// between the columns start at the diagonal. Labels are left-aligned, only
+	// the label of the last column is right-aligned.
+	p := &mockPainter{lineHeight: 10}
+	areas := paintSwitch(
+		p,
+		"subject",
+		[]string{"1", "2", "default"},
+		[]size{{40, 20}, {40, 20}, {40, 20}},
+		122, 31,
+	)
+	p.checkPainting(t,
+		`Line(0, 10, 121, 10)`,
+		`Line(0, 0, 81, 9)`,
+		`Line(81, 9, 121, 0)`,
+		`Line(40, 4, 40, 30)`,
+		`Line(81, 9, 81, 30)`,
+		`Text(80, 0, "subject")`,
+		`Text(2, 8, "1")`,
+		`Text(43, 8, "2")`,
+		`Text(120, 8, "default")`,
+	)
+	check.Eq(t, areas, []rectangle{
+		{0, 11, 40, 20},
+		{41, 11, 40, 20},
+		{82, 11, 40, 20},
+	})
+}
+
+func TestSwitchDefaultCasesArePaintedRightmost(t *testing.T) {
+	cases := switchCasesInPaintOrder([]parser.SwitchCase{
+		{IsDefault: true, Condition: parser.String{Text: "default"}},
+		{Condition: parser.String{Text: "1"}},
+		{Condition: parser.String{Text: "2"}},
+	})
+	check.Eq(t, len(cases), 3)
+	check.Eq(t, cases[0].Condition.Text, "1")
+	check.Eq(t, cases[1].Condition.Text, "2")
+	check.Eq(t, cases[2].Condition.Text, "default")
+}
+
+func TestBuiltStructogramsPaintLikeParsedOnes(t *testing.T) {
+	built := parser.NewBuilder("title").
+		Instr("a").
+		IfElse("b", func(b *parser.Builder) {
+			b.Call("c")
+		}, nil).
+		Switch("d", func(s *parser.SwitchBuilder) {
+			s.Case("e", nil).Default("", nil)
+		}).
+		Structogram()
+	parsed, err := parser.ParseString(`title "title"
+"a"
+if "b" { call "c" } else {}
+switch "d" { case "e" {} case default {} }`)
+	check.Eq(t, err, nil)
+
+	builtPainter := &mockPainter{lineHeight: 10, textW: 20, textH: 10}
+	paintStructogram(builtPainter, paintSettings{}, built)
+	parsedPainter := &mockPainter{lineHeight: 10, textW: 20, textH: 10}
+	paintStructogram(parsedPainter, paintSettings{}, parsed)
+	check.Eq(t, builtPainter.ops, parsedPainter.ops)
+}
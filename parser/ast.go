@@ -3,6 +3,45 @@ package parser
 type Structogram struct {
 	Title      String
 	Statements []Statement
+	// EndComments are the comments after the last statement.
+	EndComments []Comment
+	// Comments are the comments around the title.
+	Comments
+	titleStart Pos
+}
+
+// TitleKeyword is the keyword "title". Its positions are zero if there is no
+// title in the code.
+func (s *Structogram) TitleKeyword() Keyword {
+	return newKeyword("title", s.titleStart)
+}
+
+// Start is where the title or, if there is none, the first statement starts.
+func (s *Structogram) Start() Pos {
+	if s.titleStart != (Pos{}) {
+		return s.titleStart
+	}
+	if s.Title.given() {
+		return s.Title.Start()
+	}
+	if len(s.Statements) > 0 {
+		return s.Statements[0].Start()
+	}
+	return Pos{}
+}
+
+// End is where the last statement or, if there is none, the title ends.
+func (s *Structogram) End() Pos {
+	if len(s.Statements) > 0 {
+		return s.Statements[len(s.Statements)-1].End()
+	}
+	return s.Title.End()
+}
+
+// Node is any part of the syntax tree.
+type Node interface {
+	Start() Pos
+	End() Pos
 }
 
 type Statement interface {
@@ -10,8 +49,11 @@ type Statement interface {
 	End() Pos
 }
 
+// Pos is a position in the code. Line and Col start at 1, Col counts runes.
+// Offset is the number of bytes before the position.
 type Pos struct {
 	Col, Line int
+	Offset    int
 }
 
 type String struct {
@@ -25,7 +67,8 @@ func (s String) Start() Pos { return s.start }
 func (s String) End() Pos   { return s.end }
 
 type Instruction struct {
-	Text   string
+	Text string
+	Comments
 	quoted string
 	start  Pos
 	end    Pos
@@ -35,29 +78,98 @@ func (i Instruction) Start() Pos { return i.start }
 func (i Instruction) End() Pos   { return i.end }
 
 type Break struct {
-	Text   string
-	quoted string
-	start  Pos
-	end    Pos
+	Text string
+	Comments
+	quoted    string
+	start     Pos
+	end       Pos
+	textStart Pos
+}
+
+func (b Break) Start() Pos       { return b.start }
+func (b Break) End() Pos         { return b.end }
+func (b Break) Keyword() Keyword { return newKeyword("break", b.start) }
+
+// TextStart is where the quoted text starts, it ends at the end of the break.
+func (b Break) TextStart() Pos { return b.textStart }
+
+type Return struct {
+	Text string
+	Comments
+	quoted    string
+	start     Pos
+	end       Pos
+	textStart Pos
+}
+
+func (r Return) Start() Pos       { return r.start }
+func (r Return) End() Pos         { return r.end }
+func (r Return) Keyword() Keyword { return newKeyword("return", r.start) }
+
+// TextStart is where the quoted text starts, it ends at the end of the return.
+func (r Return) TextStart() Pos { return r.textStart }
+
+type Continue struct {
+	Text string
+	Comments
+	quoted    string
+	start     Pos
+	end       Pos
+	textStart Pos
 }
 
-func (b Break) Start() Pos { return b.start }
-func (b Break) End() Pos   { return b.end }
+func (c Continue) Start() Pos       { return c.start }
+func (c Continue) End() Pos         { return c.end }
+func (c Continue) Keyword() Keyword { return newKeyword("continue", c.start) }
+
+// TextStart is where the quoted text starts, it ends at the end of the
+// continue.
+func (c Continue) TextStart() Pos { return c.textStart }
+
+type Exit struct {
+	Text string
+	Comments
+	quoted    string
+	start     Pos
+	end       Pos
+	textStart Pos
+}
+
+func (e Exit) Start() Pos       { return e.start }
+func (e Exit) End() Pos         { return e.end }
+func (e Exit) Keyword() Keyword { return newKeyword("exit", e.start) }
+
+// TextStart is where the quoted text starts, it ends at the end of the exit.
+func (e Exit) TextStart() Pos { return e.textStart }
 
 type Call struct {
-	Text   string
-	quoted string
-	start  Pos
-	end    Pos
+	Text string
+	Comments
+	quoted    string
+	start     Pos
+	end       Pos
+	textStart Pos
 }
 
-func (c Call) Start() Pos { return c.start }
-func (c Call) End() Pos   { return c.end }
+func (c Call) Start() Pos       { return c.start }
+func (c Call) End() Pos         { return c.end }
+func (c Call) Keyword() Keyword { return newKeyword("call", c.start) }
+
+// TextStart is where the quoted text starts, it ends at the end of the call.
+func (c Call) TextStart() Pos { return c.textStart }
 
 type Block struct {
 	Statements []Statement
-	start      Pos
-	end        Pos
+	// EndComments are the comments after the last statement.
+	EndComments []Comment
+	// Leading are the comments before the opening brace, e.g. between an if's
+	// condition and its block.
+	Leading []Comment
+	// Trailing are the comments after the closing brace when more of the
+	// statement follows, e.g. between an if's block and its else.
+	Trailing []Comment
+	start    Pos
+	end      Pos
 }
 
 func (b Block) Start() Pos { return b.start }
@@ -67,11 +179,13 @@ type If struct {
 	Condition String
 	TrueText  String
 	Then      Block
-	start     Pos
+	Comments
+	start Pos
 }
 
-func (i If) Start() Pos { return i.start }
-func (i If) End() Pos   { return i.Then.End() }
+func (i If) Start() Pos       { return i.start }
+func (i If) End() Pos         { return i.Then.End() }
+func (i If) Keyword() Keyword { return newKeyword("if", i.start) }
 
 type IfElse struct {
 	Condition String
@@ -79,59 +193,280 @@ type IfElse struct {
 	Then      Block
 	FalseText String
 	Else      Block
+	Comments
+	start     Pos
+	elseStart Pos
+}
+
+func (i IfElse) Start() Pos           { return i.start }
+func (i IfElse) End() Pos             { return i.Else.End() }
+func (i IfElse) Keyword() Keyword     { return newKeyword("if", i.start) }
+func (i IfElse) ElseKeyword() Keyword { return newKeyword("else", i.elseStart) }
+
+// IfChain is an if with one or more else-if branches, e.g.
+// if "a" {} else if "b" {} else {}. The final else is optional.
+type IfChain struct {
+	Condition String
+	TrueText  String
+	Then      Block
+	ElseIfs   []ElseIf
+	// HasElse tells whether the chain ends in an else without a condition.
+	HasElse   bool
+	FalseText String
+	Else      Block
+	Comments
+	start     Pos
+	elseStart Pos
+}
+
+func (i IfChain) Start() Pos       { return i.start }
+func (i IfChain) Keyword() Keyword { return newKeyword("if", i.start) }
+
+// ElseKeyword is the keyword "else" of the final else. Its positions are zero
+// if there is no final else.
+func (i IfChain) ElseKeyword() Keyword { return newKeyword("else", i.elseStart) }
+
+func (i IfChain) End() Pos {
+	if i.HasElse {
+		return i.Else.End()
+	}
+	if len(i.ElseIfs) > 0 {
+		return i.ElseIfs[len(i.ElseIfs)-1].End()
+	}
+	return i.Then.End()
+}
+
+// ElseIf is an else-if branch of an IfChain. It starts at the "else".
+type ElseIf struct {
+	Condition String
+	TrueText  String
+	Block     Block
 	start     Pos
+	ifStart   Pos
 }
 
-func (i IfElse) Start() Pos { return i.start }
-func (i IfElse) End() Pos   { return i.Else.End() }
+func (e ElseIf) Start() Pos         { return e.start }
+func (e ElseIf) End() Pos           { return e.Block.End() }
+func (e ElseIf) Keyword() Keyword   { return newKeyword("else", e.start) }
+func (e ElseIf) IfKeyword() Keyword { return newKeyword("if", e.ifStart) }
 
 type Switch struct {
-	Subject String
-	Cases   []SwitchCase
-	start   Pos
-	end     Pos
+	Subject     String
+	Cases       []SwitchCase
+	EndComments []Comment
+	Comments
+	start Pos
+	end   Pos
 }
 
-func (s Switch) Start() Pos { return s.start }
-func (s Switch) End() Pos   { return s.end }
+func (s Switch) Start() Pos       { return s.start }
+func (s Switch) End() Pos         { return s.end }
+func (s Switch) Keyword() Keyword { return newKeyword("switch", s.start) }
 
 type SwitchCase struct {
 	IsDefault bool
 	Condition String
 	Block     Block
+	Comments
+	start        Pos
+	defaultStart Pos
 }
 
+func (c SwitchCase) Start() Pos       { return c.start }
+func (c SwitchCase) End() Pos         { return c.Block.End() }
+func (c SwitchCase) Keyword() Keyword { return newKeyword("case", c.start) }
+
+// DefaultKeyword is the keyword "default". Its positions are zero for other
+// cases.
+func (c SwitchCase) DefaultKeyword() Keyword {
+	return newKeyword("default", c.defaultStart)
+}
+
+type Try struct {
+	Block   Block
+	Catches []Catch
+	// HasFinally tells whether there is a finally block, even an empty one.
+	HasFinally bool
+	Finally    Block
+	Comments
+	start        Pos
+	finallyStart Pos
+}
+
+func (t Try) Start() Pos       { return t.start }
+func (t Try) Keyword() Keyword { return newKeyword("try", t.start) }
+
+// FinallyKeyword is the keyword "finally". Its positions are zero if there is
+// no finally block.
+func (t Try) FinallyKeyword() Keyword {
+	return newKeyword("finally", t.finallyStart)
+}
+
+func (t Try) End() Pos {
+	if t.HasFinally {
+		return t.Finally.End()
+	}
+	if len(t.Catches) > 0 {
+		return t.Catches[len(t.Catches)-1].End()
+	}
+	return t.Block.End()
+}
+
+// Catch is a catch clause of a Try. The Exception is optional.
+type Catch struct {
+	Exception String
+	Block     Block
+	start     Pos
+}
+
+func (c Catch) Start() Pos       { return c.start }
+func (c Catch) End() Pos         { return c.Block.End() }
+func (c Catch) Keyword() Keyword { return newKeyword("catch", c.start) }
+
 type Parallel struct {
-	Blocks []Block
-	start  Pos
-	end    Pos
+	Blocks      []Block
+	EndComments []Comment
+	Comments
+	start Pos
+	end   Pos
 }
 
-func (p Parallel) Start() Pos { return p.start }
-func (p Parallel) End() Pos   { return p.end }
+func (p Parallel) Start() Pos       { return p.start }
+func (p Parallel) End() Pos         { return p.end }
+func (p Parallel) Keyword() Keyword { return newKeyword("parallel", p.start) }
 
 type InfiniteLoop struct {
 	Block Block
+	Comments
 	start Pos
 }
 
-func (i InfiniteLoop) Start() Pos { return i.start }
-func (i InfiniteLoop) End() Pos   { return i.Block.End() }
+func (i InfiniteLoop) Start() Pos       { return i.start }
+func (i InfiniteLoop) End() Pos         { return i.Block.End() }
+func (i InfiniteLoop) Keyword() Keyword { return newKeyword("while", i.start) }
 
 type While struct {
 	Condition String
 	Block     Block
-	start     Pos
+	Comments
+	start Pos
 }
 
-func (w While) Start() Pos { return w.start }
-func (w While) End() Pos   { return w.Block.End() }
+func (w While) Start() Pos       { return w.start }
+func (w While) End() Pos         { return w.Block.End() }
+func (w While) Keyword() Keyword { return newKeyword("while", w.start) }
 
 type DoWhile struct {
 	Block     Block
 	Condition String
-	start     Pos
+	Comments
+	start      Pos
+	whileStart Pos
+}
+
+func (d DoWhile) Start() Pos            { return d.start }
+func (d DoWhile) End() Pos              { return d.Condition.End() }
+func (d DoWhile) Keyword() Keyword      { return newKeyword("do", d.start) }
+func (d DoWhile) WhileKeyword() Keyword { return newKeyword("while", d.whileStart) }
+
+// RepeatUntil is a loop that checks its condition after each iteration, like
+// DoWhile, but it stops once the condition is true.
+type RepeatUntil struct {
+	Block     Block
+	Condition String
+	Comments
+	start      Pos
+	untilStart Pos
+}
+
+func (r RepeatUntil) Start() Pos            { return r.start }
+func (r RepeatUntil) End() Pos              { return r.Condition.End() }
+func (r RepeatUntil) Keyword() Keyword      { return newKeyword("repeat", r.start) }
+func (r RepeatUntil) UntilKeyword() Keyword { return newKeyword("until", r.untilStart) }
+
+// For is a counting loop, e.g. for "i" "1" "10" "step 2" {}. Step is
+// optional.
+type For struct {
+	Variable String
+	From     String
+	To       String
+	Step     String
+	Block    Block
+	Comments
+	start Pos
+}
+
+func (f For) Start() Pos       { return f.start }
+func (f For) End() Pos         { return f.Block.End() }
+func (f For) Keyword() Keyword { return newKeyword("for", f.start) }
+
+// Foreach is a loop over the elements of a collection, e.g.
+// foreach "item" "in list" {}.
+type Foreach struct {
+	Variable   String
+	Collection String
+	Block      Block
+	Comments
+	start Pos
+}
+
+func (f Foreach) Start() Pos       { return f.start }
+func (f Foreach) End() Pos         { return f.Block.End() }
+func (f Foreach) Keyword() Keyword { return newKeyword("foreach", f.start) }
+
+// BadStatement stands in for invalid code that ParsePartial skipped. Text is
+// the skipped code.
+type BadStatement struct {
+	Text string
+	Comments
+	start Pos
+	end   Pos
+}
+
+func (b BadStatement) Start() Pos { return b.start }
+func (b BadStatement) End() Pos   { return b.end }
+
+// Keyword is a keyword in the code, like "if" or "while". Statements tell
+// where their keywords are, e.g. If.Keyword or IfElse.ElseKeyword. Keywords of
+// statements that were not parsed from code have zero positions.
+type Keyword struct {
+	Text  string
+	start Pos
+	end   Pos
+}
+
+func (k Keyword) Start() Pos { return k.start }
+func (k Keyword) End() Pos   { return k.end }
+
+// newKeyword returns the keyword starting at the given position. Keywords do
+// not span lines and are ASCII so we can compute their end. A zero start means
+// that the keyword is not in the code.
+func newKeyword(text string, start Pos) Keyword {
+	if start == (Pos{}) {
+		return Keyword{Text: text}
+	}
+	end := start
+	end.Col += len(text)
+	end.Offset += len(text)
+	return Keyword{Text: text, start: start, end: end}
+}
+
+// Comment is a line comment "// ..." or a block comment "/* ... */". Text
+// includes the comment markers.
+type Comment struct {
+	Text  string
+	start Pos
+	end   Pos
+}
+
+func (c Comment) Start() Pos { return c.start }
+func (c Comment) End() Pos   { return c.end }
+
+// Comments are the comments that belong to a statement. Leading comments stand
+// in the lines before it, trailing comments follow it in its last line.
+type Comments struct {
+	Leading  []Comment
+	Trailing []Comment
 }
 
-func (d DoWhile) Start() Pos { return d.start }
-func (d DoWhile) End() Pos   { return d.Condition.End() }
+func (c Comments) comments() Comments { return c }
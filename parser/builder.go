@@ -0,0 +1,312 @@
+package parser
+
+// Builder builds a Structogram statement by statement. Nested blocks are built
+// by functions that get their own Builder, e.g.
+//
+//	s := parser.NewBuilder("count to 10").
+//		Instr("x := 0").
+//		While("x < 10", func(b *parser.Builder) {
+//			b.Instr("x++")
+//		}).
+//		Structogram()
+//
+// The built statements render and format like parsed ones, only their
+// positions are not set because there is no code for them.
+type Builder struct {
+	title      String
+	statements []Statement
+}
+
+// NewBuilder starts a structogram with the given title. An empty title means no
+// title.
+func NewBuilder(title string) *Builder {
+	return &Builder{title: newOptionalString(title)}
+}
+
+// Structogram returns the structogram with all statements added so far.
+func (b *Builder) Structogram() *Structogram {
+	return &Structogram{
+		Title:      b.title,
+		Statements: append([]Statement(nil), b.statements...),
+	}
+}
+
+// Block returns the statements added so far as a block.
+func (b *Builder) Block() Block {
+	return Block{Statements: append([]Statement(nil), b.statements...)}
+}
+
+func (b *Builder) add(s Statement) *Builder {
+	b.statements = append(b.statements, s)
+	return b
+}
+
+// Instr adds an instruction.
+func (b *Builder) Instr(text string) *Builder {
+	return b.add(Instruction{Text: text, quoted: quote(text)})
+}
+
+// Call adds a call.
+func (b *Builder) Call(text string) *Builder {
+	return b.add(Call{Text: text, quoted: quote(text)})
+}
+
+// Break adds a break.
+func (b *Builder) Break(text string) *Builder {
+	return b.add(Break{Text: text, quoted: quote(text)})
+}
+
+// Return adds a return.
+func (b *Builder) Return(text string) *Builder {
+	return b.add(Return{Text: text, quoted: quote(text)})
+}
+
+// Continue adds a continue.
+func (b *Builder) Continue(text string) *Builder {
+	return b.add(Continue{Text: text, quoted: quote(text)})
+}
+
+// Exit adds an exit.
+func (b *Builder) Exit(text string) *Builder {
+	return b.add(Exit{Text: text, quoted: quote(text)})
+}
+
+// If adds an if without else.
+func (b *Builder) If(condition string, then func(*Builder)) *Builder {
+	return b.IfLabeled(condition, "", then)
+}
+
+// IfLabeled adds an if without else with a label for the true branch.
+func (b *Builder) IfLabeled(condition, trueText string, then func(*Builder)) *Builder {
+	return b.add(If{
+		Condition: newString(condition),
+		TrueText:  newOptionalString(trueText),
+		Then:      buildBlock(then),
+	})
+}
+
+// IfElse adds an if with an else branch.
+func (b *Builder) IfElse(condition string, then, els func(*Builder)) *Builder {
+	return b.IfElseLabeled(condition, "", "", then, els)
+}
+
+// IfElseLabeled adds an if with an else branch and labels for both branches.
+func (b *Builder) IfElseLabeled(
+	condition, trueText, falseText string,
+	then, els func(*Builder),
+) *Builder {
+	return b.add(IfElse{
+		Condition: newString(condition),
+		TrueText:  newOptionalString(trueText),
+		Then:      buildBlock(then),
+		FalseText: newOptionalString(falseText),
+		Else:      buildBlock(els),
+	})
+}
+
+// IfChain adds an if with else-if branches, which are added by the given
+// function.
+func (b *Builder) IfChain(condition string, then func(*Builder), branches func(*IfChainBuilder)) *Builder {
+	return b.IfChainLabeled(condition, "", then, branches)
+}
+
+// IfChainLabeled adds an if with else-if branches and a label for the first
+// branch.
+func (b *Builder) IfChainLabeled(
+	condition, trueText string,
+	then func(*Builder),
+	branches func(*IfChainBuilder),
+) *Builder {
+	c := IfChain{
+		Condition: newString(condition),
+		TrueText:  newOptionalString(trueText),
+		Then:      buildBlock(then),
+	}
+	if branches != nil {
+		branches(&IfChainBuilder{chain: &c})
+	}
+	return b.add(c)
+}
+
+// Switch adds a switch, its cases are added by the given function.
+func (b *Builder) Switch(subject string, cases func(*SwitchBuilder)) *Builder {
+	var sb SwitchBuilder
+	if cases != nil {
+		cases(&sb)
+	}
+	return b.add(Switch{Subject: newString(subject), Cases: sb.cases})
+}
+
+// Try adds a try block, its catch clauses and finally block are added by the
+// given function.
+func (b *Builder) Try(body func(*Builder), handlers func(*TryBuilder)) *Builder {
+	var tb TryBuilder
+	if handlers != nil {
+		handlers(&tb)
+	}
+	return b.add(tb.try(buildBlock(body)))
+}
+
+// While adds a loop that checks its condition before each iteration.
+func (b *Builder) While(condition string, body func(*Builder)) *Builder {
+	return b.add(While{Condition: newString(condition), Block: buildBlock(body)})
+}
+
+// Loop adds an infinite loop.
+func (b *Builder) Loop(body func(*Builder)) *Builder {
+	return b.add(InfiniteLoop{Block: buildBlock(body)})
+}
+
+// DoWhile adds a loop that checks its condition after each iteration.
+func (b *Builder) DoWhile(body func(*Builder), condition string) *Builder {
+	return b.add(DoWhile{Block: buildBlock(body), Condition: newString(condition)})
+}
+
+// RepeatUntil adds a loop that runs until its condition is true, checking it
+// after each iteration.
+func (b *Builder) RepeatUntil(body func(*Builder), condition string) *Builder {
+	return b.add(RepeatUntil{Block: buildBlock(body), Condition: newString(condition)})
+}
+
+// For adds a counting loop. The step is optional.
+func (b *Builder) For(variable, from, to, step string, body func(*Builder)) *Builder {
+	return b.add(For{
+		Variable: newString(variable),
+		From:     newString(from),
+		To:       newString(to),
+		Step:     newOptionalString(step),
+		Block:    buildBlock(body),
+	})
+}
+
+// Foreach adds a loop over the elements of a collection.
+func (b *Builder) Foreach(variable, collection string, body func(*Builder)) *Builder {
+	return b.add(Foreach{
+		Variable:   newString(variable),
+		Collection: newString(collection),
+		Block:      buildBlock(body),
+	})
+}
+
+// Parallel adds a parallel statement with one block per function.
+func (b *Builder) Parallel(blocks ...func(*Builder)) *Builder {
+	var p Parallel
+	for _, block := range blocks {
+		p.Blocks = append(p.Blocks, buildBlock(block))
+	}
+	return b.add(p)
+}
+
+// SwitchBuilder adds the cases of a switch, see Builder.Switch.
+type SwitchBuilder struct {
+	cases []SwitchCase
+}
+
+// Case adds a case.
+func (b *SwitchBuilder) Case(condition string, block func(*Builder)) *SwitchBuilder {
+	b.cases = append(b.cases, SwitchCase{
+		Condition: newString(condition),
+		Block:     buildBlock(block),
+	})
+	return b
+}
+
+// Default adds the default case. The text is optional.
+func (b *SwitchBuilder) Default(text string, block func(*Builder)) *SwitchBuilder {
+	b.cases = append(b.cases, SwitchCase{
+		IsDefault: true,
+		Condition: newOptionalString(text),
+		Block:     buildBlock(block),
+	})
+	return b
+}
+
+// IfChainBuilder adds the else-if branches and the final else of an if chain,
+// see Builder.IfChain.
+type IfChainBuilder struct {
+	chain *IfChain
+}
+
+// ElseIf adds an else-if branch.
+func (b *IfChainBuilder) ElseIf(condition string, block func(*Builder)) *IfChainBuilder {
+	return b.ElseIfLabeled(condition, "", block)
+}
+
+// ElseIfLabeled adds an else-if branch with a label.
+func (b *IfChainBuilder) ElseIfLabeled(condition, trueText string, block func(*Builder)) *IfChainBuilder {
+	b.chain.ElseIfs = append(b.chain.ElseIfs, ElseIf{
+		Condition: newString(condition),
+		TrueText:  newOptionalString(trueText),
+		Block:     buildBlock(block),
+	})
+	return b
+}
+
+// Else sets the final else.
+func (b *IfChainBuilder) Else(block func(*Builder)) *IfChainBuilder {
+	return b.ElseLabeled("", block)
+}
+
+// ElseLabeled sets the final else with a label.
+func (b *IfChainBuilder) ElseLabeled(falseText string, block func(*Builder)) *IfChainBuilder {
+	b.chain.HasElse = true
+	b.chain.FalseText = newOptionalString(falseText)
+	b.chain.Else = buildBlock(block)
+	return b
+}
+
+// TryBuilder adds the catch clauses and the finally block of a try, see
+// Builder.Try.
+type TryBuilder struct {
+	catches    []Catch
+	hasFinally bool
+	finally    Block
+}
+
+// Catch adds a catch clause. The exception is optional.
+func (b *TryBuilder) Catch(exception string, block func(*Builder)) *TryBuilder {
+	b.catches = append(b.catches, Catch{
+		Exception: newOptionalString(exception),
+		Block:     buildBlock(block),
+	})
+	return b
+}
+
+// Finally sets the finally block.
+func (b *TryBuilder) Finally(block func(*Builder)) *TryBuilder {
+	b.hasFinally = true
+	b.finally = buildBlock(block)
+	return b
+}
+
+func (b *TryBuilder) try(block Block) Try {
+	return Try{
+		Block:      block,
+		Catches:    b.catches,
+		HasFinally: b.hasFinally,
+		Finally:    b.finally,
+	}
+}
+
+// buildBlock calls build, which may be nil, on a new Builder and returns the
+// resulting block.
+func buildBlock(build func(*Builder)) Block {
+	var b Builder
+	if build != nil {
+		build(&b)
+	}
+	return b.Block()
+}
+
+func newString(text string) String {
+	return String{Text: text, quoted: quote(text)}
+}
+
+// newOptionalString returns an empty String for empty text, which means that
+// the string is left out of the code.
+func newOptionalString(text string) String {
+	if text == "" {
+		return String{}
+	}
+	return newString(text)
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gonutz/check"
+)
+
+func TestBuilderCoversAllStatements(t *testing.T) {
+	s := NewBuilder("all statements").
+		Instr(`x := "0"`).
+		Call("f()").
+		If("x > 0", func(b *Builder) {
+			b.Instr("positive")
+		}).
+		IfLabeled("x < 0", "yes", func(b *Builder) {
+			b.Break("")
+		}).
+		IfElse("a", func(b *Builder) {
+			b.Instr("then")
+		}, func(b *Builder) {
+			b.Instr("else")
+		}).
+		IfElseLabeled("b", "T", "F", func(b *Builder) {
+			b.Instr("then")
+		}, func(b *Builder) {
+			b.Instr("else")
+		}).
+		IfChain("c", func(b *Builder) {
+			b.Instr("first")
+		}, func(c *IfChainBuilder) {
+			c.ElseIf("d", func(b *Builder) {
+				b.Instr("second")
+			}).ElseIf("e", nil).Else(func(b *Builder) {
+				b.Instr("last")
+			})
+		}).
+		IfChain("f", nil, func(c *IfChainBuilder) { c.ElseIf("g", nil) }).
+		IfChainLabeled("h", "T1", nil, func(c *IfChainBuilder) {
+			c.ElseIfLabeled("i", "T2", nil).ElseLabeled("F", nil)
+		}).
+		Switch("x", func(s *SwitchBuilder) {
+			s.Case("1", func(b *Builder) {
+				b.Instr("one")
+			}).Default("other", func(b *Builder) {
+				b.Instr("many")
+			})
+		}).
+		While("x < 10", func(b *Builder) {
+			b.Instr("x++")
+			b.Continue("")
+		}).
+		Loop(func(b *Builder) {
+			b.Instr("forever")
+			b.Exit("killed")
+		}).
+		DoWhile(func(b *Builder) {
+			b.Instr("once")
+		}, "again").
+		RepeatUntil(func(b *Builder) {
+			b.Instr("try")
+		}, "done").
+		For("i", "1", "10", "step 2", func(b *Builder) {
+			b.Instr("odd")
+		}).
+		For("j", "10", "1", "", func(b *Builder) {
+			b.Instr("down")
+		}).
+		Foreach("x", "in xs", func(b *Builder) {
+			b.Instr("each")
+		}).
+		Parallel(func(b *Builder) {
+			b.Instr("left")
+		}, func(b *Builder) {
+			b.Instr("right")
+		}).
+		Try(func(b *Builder) {
+			b.Instr("risky")
+		}, func(t *TryBuilder) {
+			t.Catch("IOError e", func(b *Builder) {
+				b.Instr("handle")
+			}).Catch("", nil).Finally(func(b *Builder) {
+				b.Instr("clean up")
+			})
+		}).
+		Try(nil, func(t *TryBuilder) { t.Catch("E", nil) }).
+		Return("x").
+		Structogram()
+
+	want, err := FormatString(`title "all statements"
+"x := \"0\""
+call "f()"
+if "x > 0" { "positive" }
+if "x < 0" "yes" { break "" }
+if "a" { "then" } else { "else" }
+if "b" "T" { "then" } else "F" { "else" }
+if "c" { "first" } else if "d" { "second" } else if "e" {} else { "last" }
+if "f" {} else if "g" {}
+if "h" "T1" {} else if "i" "T2" {} else "F" {}
+switch "x" {
+	case "1" { "one" }
+	case default "other" { "many" }
+}
+while "x < 10" { "x++" continue "" }
+while { "forever" exit "killed" }
+do { "once" } while "again"
+repeat { "try" } until "done"
+for "i" "1" "10" "step 2" { "odd" }
+for "j" "10" "1" { "down" }
+foreach "x" "in xs" { "each" }
+parallel { { "left" } { "right" } }
+try { "risky" } catch "IOError e" { "handle" } catch {} finally { "clean up" }
+try {} catch "E" {}
+return "x"`)
+	check.Eq(t, err, nil)
+
+	var have bytes.Buffer
+	check.Eq(t, Fprint(&have, s), nil)
+	check.Eq(t, have.String(), want)
+}
+
+func TestBuilderWithoutTitle(t *testing.T) {
+	s := NewBuilder("").Instr("a").Structogram()
+	check.Eq(t, s.Title, String{})
+	check.Eq(t, s.Statements, []Statement{Instruction{Text: "a", quoted: `"a"`}})
+}
+
+func TestBuilderCanHaveEmptyBlocks(t *testing.T) {
+	s := NewBuilder("").If("a", nil).Parallel().Structogram()
+	check.Eq(t, s.Statements, []Statement{
+		If{Condition: String{Text: "a", quoted: `"a"`}},
+		Parallel{},
+	})
+}
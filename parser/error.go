@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Error is returned by ParseString and FormatString for invalid code. It
+// describes what the parser expected at which position in the code and what it
+// found instead.
+type Error struct {
+	// Start and End are the range of the offending token, End is exclusive.
+	Start, End Pos
+	// Expected describes what would have been valid here, e.g. "string" or
+	// "token '}'". It is empty for illegal characters.
+	Expected string
+	// Found describes the offending token, e.g. "identifier \"whlie\"" or
+	// "end of input".
+	Found string
+	// Suggestion is the keyword that the offending identifier is probably a
+	// misspelling of. It is empty if there is no such keyword.
+	Suggestion string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf(
+		"parse error: %d:%d: %s expected but found %s",
+		e.Start.Line, e.Start.Col, e.Expected, e.Found,
+	)
+	if e.Expected == "" {
+		msg = fmt.Sprintf(
+			"parse error: %d:%d: unexpected %s",
+			e.Start.Line, e.Start.Col, e.Found,
+		)
+	}
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", e.Suggestion)
+	}
+	return msg
+}
+
+// ErrorList is a list of parse errors, sorted by position.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return l[0].Error() + " (and 1 more error)"
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+func (l ErrorList) sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Start, l[j].Start
+		return a.Line < b.Line || a.Line == b.Line && a.Col < b.Col
+	})
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
 )
 
@@ -11,12 +12,181 @@ func FormatString(code string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, s); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Fprint writes the structogram to w as formatted code. Strings are written as
+// they were in the parsed code. If there is no code for them, e.g. when the
+// structogram was built in Go code, or their Text was changed since parsing,
+// their Text is quoted and escaped.
+func Fprint(w io.Writer, s *Structogram) error {
 	p := &printer{}
 	p.print(s)
 	if p.err != nil {
-		return "", p.err
+		return p.err
+	}
+	_, err := w.Write(p.Bytes())
+	return err
+}
+
+// TextEdit replaces the code from Start to End with NewText.
+type TextEdit struct {
+	Start, End Pos
+	NewText    string
+}
+
+// FormatRange formats only the statements that overlap the code between the
+// byte offsets start and end, e.g. the selection in an editor. It returns the
+// edits that format them, the rest of the code is left as it is. If the range
+// lies inside a statement's block, only the statements in that block are
+// formatted. A single statement without blocks is formatted together with the
+// statement around it, so that the braces and indentation around it are
+// formatted as well. There are no edits if the statements are already
+// formatted or the range does not touch any statement.
+func FormatRange(code string, start, end int) ([]TextEdit, error) {
+	s, err := ParseString(code)
+	if err != nil {
+		return nil, err
+	}
+
+	overlaps := func(stmt Statement) bool {
+		from, to := statementRange(stmt)
+		return from.Offset <= end && start <= to.Offset
+	}
+	statements := s.Statements
+	depth := 0
+	var first, last int
+	// outer are the statements, the range in them and their depth before we
+	// went into the last block.
+	type scope struct {
+		statements  []Statement
+		first, last int
+		depth       int
+	}
+	var outer *scope
+	for {
+		first = 0
+		for first < len(statements) && !overlaps(statements[first]) {
+			first++
+		}
+		last = first
+		for last < len(statements) && overlaps(statements[last]) {
+			last++
+		}
+		if first == last {
+			return nil, nil
+		}
+		if last-first > 1 {
+			break
+		}
+		// The range is in a single statement, if it is inside one of its
+		// blocks, we only format in that block.
+		blocks, indent := childBlocks(statements[first])
+		// A single simple statement is formatted with the statement around it.
+		if len(blocks) == 0 && outer != nil {
+			statements, first, last, depth = outer.statements, outer.first, outer.last, outer.depth
+			break
+		}
+		inner := false
+		for _, b := range blocks {
+			if b.Start().Offset < start && end < b.End().Offset {
+				outer = &scope{statements, first, last, depth}
+				statements = b.Statements
+				depth += indent
+				inner = true
+			}
+		}
+		if !inner {
+			break
+		}
+	}
+
+	from, _ := statementRange(statements[first])
+	_, to := statementRange(statements[last-1])
+	p := &printer{tabs: strings.Repeat("\t", depth)}
+	// If the statements start their line, we format the indentation as well.
+	lineStart := strings.LastIndexByte(code[:from.Offset], '\n') + 1
+	if strings.TrimLeft(code[lineStart:from.Offset], " \t\r") == "" {
+		from = Pos{Col: 1, Line: from.Line, Offset: lineStart}
+		p.WriteString(p.tabs)
+	}
+	p.printStatements(statements[first:last], nil)
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if code[from.Offset:to.Offset] == p.String() {
+		return nil, nil
 	}
-	return p.String(), nil
+	return []TextEdit{{Start: from, End: to, NewText: p.String()}}, nil
+}
+
+// statementRange returns the range of the statement including its comments.
+func statementRange(s Statement) (start, end Pos) {
+	start, end = s.Start(), s.End()
+	if c, ok := s.(interface{ comments() Comments }); ok {
+		comments := c.comments()
+		if len(comments.Leading) > 0 {
+			start = comments.Leading[0].Start()
+		}
+		if n := len(comments.Trailing); n > 0 {
+			end = comments.Trailing[n-1].End()
+		}
+	}
+	return start, end
+}
+
+// childBlocks returns the blocks of a statement and by how many tabs they are
+// indented relative to the statement.
+func childBlocks(s Statement) (blocks []Block, indent int) {
+	switch x := s.(type) {
+	case If:
+		return []Block{x.Then}, 1
+	case IfElse:
+		return []Block{x.Then, x.Else}, 1
+	case IfChain:
+		blocks = append(blocks, x.Then)
+		for _, e := range x.ElseIfs {
+			blocks = append(blocks, e.Block)
+		}
+		if x.HasElse {
+			blocks = append(blocks, x.Else)
+		}
+		return blocks, 1
+	case Try:
+		blocks = append(blocks, x.Block)
+		for _, c := range x.Catches {
+			blocks = append(blocks, c.Block)
+		}
+		if x.HasFinally {
+			blocks = append(blocks, x.Finally)
+		}
+		return blocks, 1
+	case InfiniteLoop:
+		return []Block{x.Block}, 1
+	case While:
+		return []Block{x.Block}, 1
+	case DoWhile:
+		return []Block{x.Block}, 1
+	case RepeatUntil:
+		return []Block{x.Block}, 1
+	case For:
+		return []Block{x.Block}, 1
+	case Foreach:
+		return []Block{x.Block}, 1
+	case Switch:
+		for _, c := range x.Cases {
+			blocks = append(blocks, c.Block)
+		}
+		return blocks, 2
+	case Parallel:
+		return x.Blocks, 2
+	}
+	return nil, 0
 }
 
 type printer struct {
@@ -35,86 +205,106 @@ func (p *printer) print(node interface{}) {
 	}
 	switch x := node.(type) {
 	case *Structogram:
-		if x.Title.quoted != "" {
+		if x.Title.given() {
+			for _, c := range x.Leading {
+				p.WriteString(c.Text)
+				p.newLine()
+			}
 			p.WriteString("title ")
-			p.WriteString(x.Title.quoted)
-			if len(x.Statements) > 0 {
+			p.WriteString(x.Title.source())
+			p.printTrailing(x.Trailing)
+			if len(x.Statements) > 0 || len(x.EndComments) > 0 {
 				p.WriteString("\n\n")
 			}
 		}
-		for i, stmt := range x.Statements {
-			p.print(stmt)
-			if i+1 < len(x.Statements) {
-				a := x.Statements[i]
-				b := x.Statements[i+1]
-				if b.Start().Line-a.End().Line >= 2 {
-					p.WriteString("\n")
-				}
-			}
+		if p.printStatements(x.Statements, x.EndComments) {
 			p.newLine()
 		}
 	case Instruction:
-		p.WriteString(x.quoted)
+		p.WriteString(source(x.quoted, x.Text))
 	case Call:
 		p.WriteString("call ")
-		p.WriteString(x.quoted)
+		p.WriteString(source(x.quoted, x.Text))
 	case Break:
 		p.WriteString("break ")
-		p.WriteString(x.quoted)
+		p.WriteString(source(x.quoted, x.Text))
+	case Return:
+		p.WriteString("return ")
+		p.WriteString(source(x.quoted, x.Text))
+	case Continue:
+		p.WriteString("continue ")
+		p.WriteString(source(x.quoted, x.Text))
+	case Exit:
+		p.WriteString("exit ")
+		p.WriteString(source(x.quoted, x.Text))
 	case If:
 		p.WriteString("if ")
-		p.WriteString(x.Condition.quoted)
-		if x.TrueText.quoted != "" {
+		p.WriteString(x.Condition.source())
+		if x.TrueText.given() {
 			p.WriteString(" ")
-			p.WriteString(x.TrueText.quoted)
+			p.WriteString(x.TrueText.source())
 		}
-		p.WriteString(" {")
-		p.indentRight()
-		p.newLine()
-		p.print(x.Then)
-		p.indentLeft()
-		p.newLine()
-		p.WriteString("}")
+		p.printBlock(x.Then)
 	case IfElse:
 		p.WriteString("if ")
-		p.WriteString(x.Condition.quoted)
-		if x.TrueText.quoted != "" {
+		p.WriteString(x.Condition.source())
+		if x.TrueText.given() {
 			p.WriteString(" ")
-			p.WriteString(x.TrueText.quoted)
+			p.WriteString(x.TrueText.source())
 		}
-		p.WriteString(" {")
-		p.indentRight()
-		p.newLine()
-		p.print(x.Then)
-		p.indentLeft()
-		p.newLine()
-		p.WriteString("} else ")
-		if x.FalseText.quoted != "" {
-			p.WriteString(x.FalseText.quoted)
+		p.printBlock(x.Then)
+		p.continueLine("else")
+		if x.FalseText.given() {
 			p.WriteString(" ")
+			p.WriteString(x.FalseText.source())
 		}
-		p.WriteString("{")
-		p.indentRight()
-		p.newLine()
-		p.print(x.Else)
-		p.indentLeft()
-		p.newLine()
-		p.WriteString("}")
-	case Block:
-		for i, stmt := range x.Statements {
-			if i > 0 {
-				a := x.Statements[i-1]
-				b := x.Statements[i]
-				if b.Start().Line-a.End().Line >= 2 {
-					p.WriteString("\n")
-				}
-				p.newLine()
+		p.printBlock(x.Else)
+	case IfChain:
+		// Else-if branches stay at the level of the if, like in Go.
+		p.WriteString("if ")
+		p.WriteString(x.Condition.source())
+		if x.TrueText.given() {
+			p.WriteString(" ")
+			p.WriteString(x.TrueText.source())
+		}
+		p.printBlock(x.Then)
+		for _, e := range x.ElseIfs {
+			p.continueLine("else if ")
+			p.WriteString(e.Condition.source())
+			if e.TrueText.given() {
+				p.WriteString(" ")
+				p.WriteString(e.TrueText.source())
 			}
-			p.print(stmt)
+			p.printBlock(e.Block)
 		}
+		if x.HasElse {
+			p.continueLine("else")
+			if x.FalseText.given() {
+				p.WriteString(" ")
+				p.WriteString(x.FalseText.source())
+			}
+			p.printBlock(x.Else)
+		}
+	case Try:
+		p.WriteString("try")
+		p.printBlock(x.Block)
+		for _, c := range x.Catches {
+			p.continueLine("catch")
+			if c.Exception.given() {
+				p.WriteString(" ")
+				p.WriteString(c.Exception.source())
+			}
+			p.printBlock(c.Block)
+		}
+		if x.HasFinally {
+			p.continueLine("finally")
+			p.printBlock(x.Finally)
+		}
+	case Block:
+		p.printStatements(x.Statements, x.EndComments)
 	case Switch:
 		p.WriteString("switch ")
-		p.WriteString(x.Subject.quoted)
+		p.WriteString(x.Subject.source())
 		p.WriteString(" {")
 		p.indentRight()
 		p.newLine()
@@ -122,68 +312,71 @@ func (p *printer) print(node interface{}) {
 			if i > 0 {
 				p.newLine()
 			}
-			p.WriteString("case ")
+			for _, comment := range c.Leading {
+				p.WriteString(comment.Text)
+				p.newLine()
+			}
+			p.WriteString("case")
 			if c.IsDefault {
-				p.WriteString("default ")
+				p.WriteString(" default")
 			}
-			if c.Condition.quoted != "" {
-				p.WriteString(c.Condition.quoted)
+			if !c.IsDefault || c.Condition.given() {
 				p.WriteString(" ")
+				p.WriteString(c.Condition.source())
 			}
-			p.WriteString("{")
-			p.indentRight()
-			p.newLine()
-			p.print(c.Block)
-			p.indentLeft()
-			p.newLine()
-			p.WriteString("}")
+			p.printBlock(c.Block)
+			p.printTrailing(c.Trailing)
 		}
+		p.printEndComments(len(x.Cases) > 0, x.EndComments)
 		p.indentLeft()
 		p.newLine()
 		p.WriteString("}")
 	case InfiniteLoop:
-		p.WriteString("while {")
-		p.indentRight()
-		p.newLine()
-		p.print(x.Block)
-		p.indentLeft()
-		p.newLine()
-		p.WriteString("}")
+		p.WriteString("while")
+		p.printBlock(x.Block)
 	case While:
 		p.WriteString("while ")
-		p.WriteString(x.Condition.quoted)
-		p.WriteString(" {")
-		p.indentRight()
-		p.newLine()
-		p.print(x.Block)
-		p.indentLeft()
-		p.newLine()
-		p.WriteString("}")
+		p.WriteString(x.Condition.source())
+		p.printBlock(x.Block)
 	case DoWhile:
-		p.WriteString("do {")
-		p.indentRight()
-		p.newLine()
-		p.print(x.Block)
-		p.indentLeft()
-		p.newLine()
-		p.WriteString("} while ")
-		p.WriteString(x.Condition.quoted)
+		p.WriteString("do")
+		p.printBlock(x.Block)
+		p.continueLine("while ")
+		p.WriteString(x.Condition.source())
+	case RepeatUntil:
+		p.WriteString("repeat")
+		p.printBlock(x.Block)
+		p.continueLine("until ")
+		p.WriteString(x.Condition.source())
+	case For:
+		p.WriteString("for ")
+		p.WriteString(x.Variable.source())
+		p.WriteString(" ")
+		p.WriteString(x.From.source())
+		p.WriteString(" ")
+		p.WriteString(x.To.source())
+		if x.Step.given() {
+			p.WriteString(" ")
+			p.WriteString(x.Step.source())
+		}
+		p.printBlock(x.Block)
+	case Foreach:
+		p.WriteString("foreach ")
+		p.WriteString(x.Variable.source())
+		p.WriteString(" ")
+		p.WriteString(x.Collection.source())
+		p.printBlock(x.Block)
 	case Parallel:
 		p.WriteString("parallel {")
 		p.indentRight()
 		p.newLine()
 		for i, b := range x.Blocks {
-			if i > 0 {
+			if i > 0 && !p.atLineStart() {
 				p.newLine()
 			}
-			p.WriteString("{")
-			p.indentRight()
-			p.newLine()
-			p.print(b)
-			p.indentLeft()
-			p.newLine()
-			p.WriteString("}")
+			p.printBlock(b)
 		}
+		p.printEndComments(len(x.Blocks) > 0, x.EndComments)
 		p.indentLeft()
 		p.newLine()
 		p.WriteString("}")
@@ -192,6 +385,135 @@ func (p *printer) print(node interface{}) {
 	}
 }
 
+// printStatements prints the statements with their comments and the comments
+// after them, one per line. Single empty lines between them are kept. It
+// returns whether anything was printed.
+func (p *printer) printStatements(statements []Statement, endComments []Comment) bool {
+	printed := false
+	lastLine := 0
+	// next starts a new line for something that starts in the given line.
+	next := func(line int) {
+		if printed {
+			if line-lastLine >= 2 {
+				p.WriteString("\n")
+			}
+			p.newLine()
+		}
+		printed = true
+	}
+	for _, stmt := range statements {
+		var comments Comments
+		if c, ok := stmt.(interface{ comments() Comments }); ok {
+			comments = c.comments()
+		}
+		for _, c := range comments.Leading {
+			next(c.Start().Line)
+			p.WriteString(c.Text)
+			lastLine = c.End().Line
+		}
+		next(stmt.Start().Line)
+		p.print(stmt)
+		lastLine = stmt.End().Line
+		p.printTrailing(comments.Trailing)
+		if n := len(comments.Trailing); n > 0 {
+			lastLine = comments.Trailing[n-1].End().Line
+		}
+	}
+	for _, c := range endComments {
+		next(c.Start().Line)
+		p.WriteString(c.Text)
+		lastLine = c.End().Line
+	}
+	return printed
+}
+
+// printBlock prints the block in braces with the comments before and after
+// the braces. The block goes after the code in the current line.
+func (p *printer) printBlock(b Block) {
+	for _, c := range b.Leading {
+		p.continueLine(c.Text)
+		p.endLineComment(c)
+	}
+	p.continueLine("{")
+	p.indentRight()
+	p.newLine()
+	p.print(b)
+	p.indentLeft()
+	p.newLine()
+	p.WriteString("}")
+	for _, c := range b.Trailing {
+		p.continueLine(c.Text)
+		p.endLineComment(c)
+	}
+}
+
+// continueLine writes s after a space, or at the start of the line if a line
+// comment just ended the previous one.
+func (p *printer) continueLine(s string) {
+	if !p.atLineStart() {
+		p.WriteString(" ")
+	}
+	p.WriteString(s)
+}
+
+// endLineComment starts a new line after a line comment, code after it would
+// be part of the comment.
+func (p *printer) endLineComment(c Comment) {
+	if strings.HasPrefix(c.Text, "//") {
+		p.newLine()
+	}
+}
+
+func (p *printer) atLineStart() bool {
+	return p.Len() == 0 || bytes.HasSuffix(p.Bytes(), []byte("\n"+p.tabs))
+}
+
+// printTrailing prints comments at the end of the current line.
+func (p *printer) printTrailing(comments []Comment) {
+	for _, c := range comments {
+		p.WriteString(" ")
+		p.WriteString(c.Text)
+	}
+}
+
+// printEndComments prints comments in their own lines before the closing brace
+// of a switch or parallel statement. newLine tells whether they go below
+// other code.
+func (p *printer) printEndComments(newLine bool, comments []Comment) {
+	for i, c := range comments {
+		if newLine || i > 0 {
+			p.newLine()
+		}
+		p.WriteString(c.Text)
+	}
+}
+
+// given tells whether an optional string is part of the code.
+func (s String) given() bool {
+	return s.quoted != "" || s.Text != ""
+}
+
+func (s String) source() string {
+	return source(s.quoted, s.Text)
+}
+
+// source returns the code for a string with the given text. quoted is the
+// string as it was parsed, it is kept as long as it matches the text.
+func source(quoted, text string) string {
+	if strings.HasPrefix(quoted, `"`) && len(quoted) >= 2 &&
+		escapeString(quoted) == text {
+		return quoted
+	}
+	return quote(text)
+}
+
+// quote turns text into a string literal, it is the inverse of escapeString.
+func quote(text string) string {
+	return `"` + quoter.Replace(text) + `"`
+}
+
+var quoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
 func (p *printer) indentRight() {
 	p.tabs += "\t"
 }
@@ -1,6 +1,12 @@
 package parser
 
-import "testing"
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+)
 
 func TestFormatter(t *testing.T) {
 	checkFormatting(t, `
@@ -279,6 +285,82 @@ do {
 `)
 }
 
+func TestFormatForLoops(t *testing.T) {
+	checkFormatting(t,
+		`for"i""1""10""step 2"{"odd"}for "j"  "10" "1" {
+ "down"}
+foreach  "x""in xs"{"each"}`,
+
+		`for "i" "1" "10" "step 2" {
+	"odd"
+}
+for "j" "10" "1" {
+	"down"
+}
+foreach "x" "in xs" {
+	"each"
+}
+`)
+}
+
+func TestFormatElseIfChainsFlat(t *testing.T) {
+	checkFormatting(t,
+		`if"a"{"b"}else  if"c""T"{"d"}else
+if "e" {} else"F"{if"g"{}else if"h"{}}`,
+
+		`if "a" {
+	"b"
+} else if "c" "T" {
+	"d"
+} else if "e" {
+	
+} else "F" {
+	if "g" {
+		
+	} else if "h" {
+		
+	}
+}
+`)
+}
+
+func TestFormatRepeatUntil(t *testing.T) {
+	checkFormatting(t,
+		`repeat{"a"}until"b"  repeat {}
+  until "c"`,
+
+		`repeat {
+	"a"
+} until "b"
+repeat {
+	
+} until "c"
+`)
+}
+
+func TestFormatTryLikeIfElse(t *testing.T) {
+	checkFormatting(t,
+		`try{"a"}catch"E"{"b"}  catch
+{}finally{"c"}
+try {} catch "F" {}`,
+
+		`try {
+	"a"
+} catch "E" {
+	"b"
+} catch {
+	
+} finally {
+	"c"
+}
+try {
+	
+} catch "F" {
+	
+}
+`)
+}
+
 func TestFormatParallelBlocks(t *testing.T) {
 	checkFormatting(t,
 		`parallel{}parallel{{}}
@@ -326,6 +408,333 @@ if "" {
 `)
 }
 
+func TestCommentsAreKeptInPlace(t *testing.T) {
+	checkFormatting(t, `// file header
+title "t" // about the title
+// first
+  "a"   // trailing
+/* block */ "b"
+
+	// before if
+if "c" { // moves to its own line
+		"d"
+  // end of block
+} else {
+// only a comment
+}
+switch "s" {
+	// case comment
+case "x" { "y" } // after case
+	// end of switch
+}
+parallel {
+	{ "p" }
+	// end of parallel
+}
+/* multi
+   line */
+// end of file
+`,
+
+		`// file header
+title "t" // about the title
+
+// first
+"a" // trailing
+/* block */
+"b"
+
+// before if
+if "c" {
+	// moves to its own line
+	"d"
+	// end of block
+} else {
+	// only a comment
+}
+switch "s" {
+	// case comment
+	case "x" {
+		"y"
+	} // after case
+	// end of switch
+}
+parallel {
+	{
+		"p"
+	}
+	// end of parallel
+}
+/* multi
+   line */
+// end of file
+`)
+}
+
+func TestCommentsAroundBracesStayWithTheirBlock(t *testing.T) {
+	checkFormatting(t, `if "a" {} // c
+else {}`,
+
+		`if "a" {
+	
+} // c
+else {
+	
+}
+`)
+
+	checkFormatting(t, `try {} /*x*/ catch "e" {} // y
+finally {}`,
+
+		`try {
+	
+} /*x*/ catch "e" {
+	
+} // y
+finally {
+	
+}
+`)
+
+	checkFormatting(t, `parallel { {} // p
+{} }`,
+
+		`parallel {
+	{
+		
+	} // p
+	{
+		
+	}
+}
+`)
+
+	checkFormatting(t, `switch "x" { case /*c*/ "1" {} }`,
+
+		`switch "x" {
+	case "1" /*c*/ {
+		
+	}
+}
+`)
+
+	checkFormatting(t, `if "a" /*b*/ {} /*c*/ else if "d" {} /*e*/ else /*f*/ {}
+do {} /*g*/ while "h"
+repeat {} // i
+until "j"`,
+
+		`if "a" /*b*/ {
+	
+} /*c*/ else if "d" {
+	
+} /*e*/ else /*f*/ {
+	
+}
+do {
+	
+} /*g*/ while "h"
+repeat {
+	
+} // i
+until "j"
+`)
+}
+
+func TestOnlyCommentsAreFormatted(t *testing.T) {
+	checkFormatting(t, "// a\n\n\n// b", "// a\n\n// b\n")
+}
+
+func TestFprintQuotesTextOfBuiltStructograms(t *testing.T) {
+	var buf bytes.Buffer
+	err := Fprint(&buf, &Structogram{
+		Title: String{Text: "built"},
+		Statements: []Statement{
+			Instruction{Text: `say "hi"`},
+			IfElse{
+				Condition: String{Text: `a\b`},
+				Then:      Block{Statements: []Statement{Call{Text: "f()"}}},
+				FalseText: String{Text: "no"},
+				Else:      Block{Statements: []Statement{Break{}}},
+			},
+			Switch{
+				Subject: String{Text: "x"},
+				Cases: []SwitchCase{
+					{Block: Block{Statements: []Statement{Instruction{}}}},
+					{
+						IsDefault: true,
+						Block:     Block{Statements: []Statement{Instruction{}}},
+					},
+				},
+			},
+			DoWhile{
+				Block:     Block{Statements: []Statement{Instruction{Text: "a"}}},
+				Condition: String{Text: "line\nbreak"},
+			},
+		},
+	})
+	check.Eq(t, err, nil)
+	check.Eq(t, buf.String(), `title "built"
+
+"say \"hi\""
+if "a\\b" {
+	call "f()"
+} else "no" {
+	break ""
+}
+switch "x" {
+	case "" {
+		""
+	}
+	case default {
+		""
+	}
+}
+do {
+	"a"
+} while "line\nbreak"
+`)
+
+	s, err := ParseString(buf.String())
+	check.Eq(t, err, nil)
+	check.Eq(t, s.Statements[0].(Instruction).Text, `say "hi"`)
+	check.Eq(t, s.Statements[1].(IfElse).Condition.Text, `a\b`)
+	check.Eq(t, s.Statements[3].(DoWhile).Condition.Text, "line\nbreak")
+}
+
+func TestFprintQuotesChangedText(t *testing.T) {
+	s, err := ParseString(`"keep\nescapes" "old"`)
+	check.Eq(t, err, nil)
+	second := s.Statements[1].(Instruction)
+	second.Text = "new"
+	s.Statements[1] = second
+	var buf bytes.Buffer
+	check.Eq(t, Fprint(&buf, s), nil)
+	check.Eq(t, buf.String(), `"keep\nescapes"
+"new"
+`)
+}
+
+func TestEscapedBackslashBeforeN(t *testing.T) {
+	check.Eq(t, escapeString(`"\\n"`), `\n`)
+	check.Eq(t, quote(`\n`), `"\\n"`)
+}
+
+func TestFormatRangeFormatsOnlyTheSelectedStatements(t *testing.T) {
+	checkRangeFormatting(t, `
+"a"   // a
+  if   "b"   {"c"}
+  "d"
+"e"     `, `"b"`, `
+"a"   // a
+if "b" {
+	"c"
+}
+  "d"
+"e"     `)
+
+	checkRangeFormatting(t, `"a"
+  "b"
+  "c"
+  "d"`, "b\"\n  \"c", `"a"
+"b"
+"c"
+  "d"`)
+}
+
+func TestFormatRangeFormatsInsideBlocks(t *testing.T) {
+	checkRangeFormatting(t, `while {
+	"a"
+	switch "b" { case "c" {
+"d"  // comment
+   "e"
+	}}
+}`, "\"d\"  // comment\n   \"e\"", `while {
+	"a"
+	switch "b" { case "c" {
+			"d" // comment
+			"e"
+	}}
+}`)
+}
+
+func TestFormatRangeFormatsSingleSimpleStatementsWithTheirParent(t *testing.T) {
+	checkRangeFormatting(t, `while {
+    "a"
+  if "x" {"y"}
+}`, `"y"`, `while {
+    "a"
+	if "x" {
+		"y"
+	}
+}`)
+
+	checkRangeFormatting(t, `switch "s" {
+case "1" {  "a"  }
+}`, `"a"`, `switch "s" {
+	case "1" {
+		"a"
+	}
+}`)
+
+	checkRangeFormatting(t, `while {
+	"a"
+	switch "b" { case "c" {    "d"     } }
+}`, `"d"`, `while {
+	"a"
+	switch "b" {
+		case "c" {
+			"d"
+		}
+	}
+}`)
+}
+
+func TestFormatRangeOfFormattedCodeHasNoEdits(t *testing.T) {
+	edits, err := FormatRange("\"a\"\n\nwhile {\n\t\"b\"\n}\n", 0, 100)
+	check.Eq(t, err, nil)
+	check.Eq(t, len(edits), 0)
+}
+
+func TestFormatRangeOutsideStatementsHasNoEdits(t *testing.T) {
+	edits, err := FormatRange("\"a\"\n\n  \n\"b\"", 5, 6)
+	check.Eq(t, err, nil)
+	check.Eq(t, len(edits), 0)
+}
+
+func TestFormatRangeEditHasPositions(t *testing.T) {
+	edits, err := FormatRange("\"a\"\n  \"b\"", 6, 6)
+	check.Eq(t, err, nil)
+	check.Eq(t, edits, []TextEdit{{
+		Start:   Pos{Col: 1, Line: 2, Offset: 4},
+		End:     Pos{Col: 6, Line: 2, Offset: 9},
+		NewText: `"b"`,
+	}})
+}
+
+func TestFormatRangeNeedsValidCode(t *testing.T) {
+	_, err := FormatRange(`if {}`, 0, 1)
+	check.Eq(t, err.Error(), "parse error: 1:4: string expected but found token '{'")
+}
+
+// checkRangeFormatting formats the range of the selected text in the original
+// code.
+func checkRangeFormatting(t *testing.T, original, selected, want string) {
+	t.Helper()
+	start := strings.Index(original, selected)
+	edits, err := FormatRange(original, start, start+len(selected))
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := original
+	for i := len(edits) - 1; i >= 0; i-- {
+		e := edits[i]
+		have = have[:e.Start.Offset] + e.NewText + have[e.End.Offset:]
+	}
+	if have != want {
+		t.Errorf("have\n---\n%s\n---\nbut want\n---\n%s\n---", have, want)
+	}
+}
+
 func checkFormatting(t *testing.T, original, want string) {
 	t.Helper()
 	have, err := FormatString(original)
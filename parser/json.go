@@ -0,0 +1,700 @@
+package parser
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONSchema is the JSON Schema of the JSON encoding of a Structogram, see
+// Structogram.MarshalJSON.
+//
+//go:embed structogram.schema.json
+var JSONSchema string
+
+// MarshalJSON encodes the structogram as a JSON object. Every statement is an
+// object with a "kind" that tells its type, e.g. "instruction" or "while".
+// Nodes have "start" and "end" positions with line, column and byte offset,
+// statements list their "keywords" with positions as well. Strings have their
+// "text" and, if the structogram was parsed, the "quoted" string from the
+// code. The encoding is lossless, UnmarshalJSON restores the same structogram.
+// The format is described by JSONSchema.
+func (s *Structogram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonStructogram{
+		Title:       encodeString(s.Title),
+		Keywords:    encodeKeywords(s.TitleKeyword()),
+		Statements:  encodeStatements(s.Statements),
+		Leading:     encodeComments(s.Leading),
+		Trailing:    encodeComments(s.Trailing),
+		EndComments: encodeComments(s.EndComments),
+	})
+}
+
+// UnmarshalJSON decodes a structogram from the JSON encoding described in
+// MarshalJSON.
+func (s *Structogram) UnmarshalJSON(data []byte) error {
+	var j jsonStructogram
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	statements, err := decodeStatements(j.Statements)
+	if err != nil {
+		return err
+	}
+	*s = Structogram{
+		titleStart:  decodeKeyword(j.Keywords, "title"),
+		Title:       decodeString(j.Title),
+		Statements:  statements,
+		EndComments: decodeComments(j.EndComments),
+		Comments: Comments{
+			Leading:  decodeComments(j.Leading),
+			Trailing: decodeComments(j.Trailing),
+		},
+	}
+	return nil
+}
+
+// Statement kinds in the JSON encoding.
+const (
+	kindInstruction  = "instruction"
+	kindCall         = "call"
+	kindBreak        = "break"
+	kindReturn       = "return"
+	kindContinue     = "continue"
+	kindExit         = "exit"
+	kindIf           = "if"
+	kindIfElse       = "ifElse"
+	kindIfChain      = "ifChain"
+	kindTry          = "try"
+	kindSwitch       = "switch"
+	kindParallel     = "parallel"
+	kindInfiniteLoop = "infiniteLoop"
+	kindWhile        = "while"
+	kindDoWhile      = "doWhile"
+	kindRepeatUntil  = "repeatUntil"
+	kindFor          = "for"
+	kindForeach      = "foreach"
+	kindBad          = "bad"
+)
+
+type jsonStructogram struct {
+	Title       *jsonString     `json:"title,omitempty"`
+	Keywords    []jsonKeyword   `json:"keywords,omitempty"`
+	Statements  []jsonStatement `json:"statements"`
+	Leading     []jsonComment   `json:"leading,omitempty"`
+	Trailing    []jsonComment   `json:"trailing,omitempty"`
+	EndComments []jsonComment   `json:"endComments,omitempty"`
+}
+
+// jsonStatement has the fields of all kinds of statements, only those of its
+// kind are set.
+type jsonStatement struct {
+	Kind        string        `json:"kind"`
+	Keywords    []jsonKeyword `json:"keywords,omitempty"`
+	Text        string        `json:"text,omitempty"`
+	Quoted      string        `json:"quoted,omitempty"`
+	TextStart   *jsonPos      `json:"textStart,omitempty"`
+	Subject     *jsonString   `json:"subject,omitempty"`
+	Condition   *jsonString   `json:"condition,omitempty"`
+	TrueText    *jsonString   `json:"trueText,omitempty"`
+	FalseText   *jsonString   `json:"falseText,omitempty"`
+	Variable    *jsonString   `json:"variable,omitempty"`
+	From        *jsonString   `json:"from,omitempty"`
+	To          *jsonString   `json:"to,omitempty"`
+	Step        *jsonString   `json:"step,omitempty"`
+	Collection  *jsonString   `json:"collection,omitempty"`
+	Then        *jsonBlock    `json:"then,omitempty"`
+	Else        *jsonBlock    `json:"else,omitempty"`
+	Block       *jsonBlock    `json:"block,omitempty"`
+	Blocks      []jsonBlock   `json:"blocks,omitempty"`
+	Cases       []jsonCase    `json:"cases,omitempty"`
+	ElseIfs     []jsonElseIf  `json:"elseIfs,omitempty"`
+	Catches     []jsonCatch   `json:"catches,omitempty"`
+	Finally     *jsonBlock    `json:"finally,omitempty"`
+	Leading     []jsonComment `json:"leading,omitempty"`
+	Trailing    []jsonComment `json:"trailing,omitempty"`
+	EndComments []jsonComment `json:"endComments,omitempty"`
+	Start       *jsonPos      `json:"start,omitempty"`
+	End         *jsonPos      `json:"end,omitempty"`
+}
+
+type jsonString struct {
+	Text   string   `json:"text"`
+	Quoted string   `json:"quoted,omitempty"`
+	Start  *jsonPos `json:"start,omitempty"`
+	End    *jsonPos `json:"end,omitempty"`
+}
+
+type jsonBlock struct {
+	Statements  []jsonStatement `json:"statements"`
+	EndComments []jsonComment   `json:"endComments,omitempty"`
+	Leading     []jsonComment   `json:"leading,omitempty"`
+	Trailing    []jsonComment   `json:"trailing,omitempty"`
+	Start       *jsonPos        `json:"start,omitempty"`
+	End         *jsonPos        `json:"end,omitempty"`
+}
+
+type jsonCase struct {
+	Keywords  []jsonKeyword `json:"keywords,omitempty"`
+	IsDefault bool          `json:"isDefault,omitempty"`
+	Condition *jsonString   `json:"condition,omitempty"`
+	Block     jsonBlock     `json:"block"`
+	Leading   []jsonComment `json:"leading,omitempty"`
+	Trailing  []jsonComment `json:"trailing,omitempty"`
+	Start     *jsonPos      `json:"start,omitempty"`
+	End       *jsonPos      `json:"end,omitempty"`
+}
+
+type jsonElseIf struct {
+	Keywords  []jsonKeyword `json:"keywords,omitempty"`
+	Condition *jsonString   `json:"condition"`
+	TrueText  *jsonString   `json:"trueText,omitempty"`
+	Block     jsonBlock     `json:"block"`
+	Start     *jsonPos      `json:"start,omitempty"`
+	End       *jsonPos      `json:"end,omitempty"`
+}
+
+type jsonCatch struct {
+	Keywords  []jsonKeyword `json:"keywords,omitempty"`
+	Exception *jsonString   `json:"exception,omitempty"`
+	Block     jsonBlock     `json:"block"`
+	Start     *jsonPos      `json:"start,omitempty"`
+	End       *jsonPos      `json:"end,omitempty"`
+}
+
+type jsonComment struct {
+	Text  string   `json:"text"`
+	Start *jsonPos `json:"start,omitempty"`
+	End   *jsonPos `json:"end,omitempty"`
+}
+
+type jsonKeyword struct {
+	Text  string   `json:"text"`
+	Start *jsonPos `json:"start"`
+	End   *jsonPos `json:"end"`
+}
+
+type jsonPos struct {
+	Line   int `json:"line"`
+	Col    int `json:"col"`
+	Offset int `json:"offset"`
+}
+
+// encodePos returns nil for the zero position of nodes that were not parsed.
+func encodePos(p Pos) *jsonPos {
+	if p == (Pos{}) {
+		return nil
+	}
+	return &jsonPos{Line: p.Line, Col: p.Col, Offset: p.Offset}
+}
+
+func decodePos(p *jsonPos) Pos {
+	if p == nil {
+		return Pos{}
+	}
+	return Pos{Line: p.Line, Col: p.Col, Offset: p.Offset}
+}
+
+// encodeKeywords leaves out keywords that are not in the code.
+func encodeKeywords(keywords ...Keyword) []jsonKeyword {
+	var j []jsonKeyword
+	for _, k := range keywords {
+		if k.start != (Pos{}) {
+			j = append(j, jsonKeyword{
+				Text:  k.Text,
+				Start: encodePos(k.start),
+				End:   encodePos(k.end),
+			})
+		}
+	}
+	return j
+}
+
+// decodeKeyword returns the start of the keyword with the given text. The
+// other keywords of a node start where the node starts.
+func decodeKeyword(j []jsonKeyword, text string) Pos {
+	for _, k := range j {
+		if k.Text == text {
+			return decodePos(k.Start)
+		}
+	}
+	return Pos{}
+}
+
+// encodeString returns nil for strings that are left out of the code.
+func encodeString(s String) *jsonString {
+	if s == (String{}) {
+		return nil
+	}
+	return &jsonString{
+		Text:   s.Text,
+		Quoted: s.quoted,
+		Start:  encodePos(s.start),
+		End:    encodePos(s.end),
+	}
+}
+
+func decodeString(s *jsonString) String {
+	if s == nil {
+		return String{}
+	}
+	return String{
+		Text:   s.Text,
+		quoted: s.Quoted,
+		start:  decodePos(s.Start),
+		end:    decodePos(s.End),
+	}
+}
+
+func encodeComments(comments []Comment) []jsonComment {
+	var j []jsonComment
+	for _, c := range comments {
+		j = append(j, jsonComment{
+			Text:  c.Text,
+			Start: encodePos(c.start),
+			End:   encodePos(c.end),
+		})
+	}
+	return j
+}
+
+func decodeComments(j []jsonComment) []Comment {
+	var comments []Comment
+	for _, c := range j {
+		comments = append(comments, Comment{
+			Text:  c.Text,
+			start: decodePos(c.Start),
+			end:   decodePos(c.End),
+		})
+	}
+	return comments
+}
+
+func encodeBlock(b Block) jsonBlock {
+	return jsonBlock{
+		Statements:  encodeStatements(b.Statements),
+		EndComments: encodeComments(b.EndComments),
+		Leading:     encodeComments(b.Leading),
+		Trailing:    encodeComments(b.Trailing),
+		Start:       encodePos(b.start),
+		End:         encodePos(b.end),
+	}
+}
+
+func decodeBlock(j *jsonBlock) (Block, error) {
+	if j == nil {
+		return Block{}, nil
+	}
+	statements, err := decodeStatements(j.Statements)
+	return Block{
+		Statements:  statements,
+		EndComments: decodeComments(j.EndComments),
+		Leading:     decodeComments(j.Leading),
+		Trailing:    decodeComments(j.Trailing),
+		start:       decodePos(j.Start),
+		end:         decodePos(j.End),
+	}, err
+}
+
+func encodeStatements(statements []Statement) []jsonStatement {
+	j := []jsonStatement{}
+	for _, s := range statements {
+		j = append(j, encodeStatement(s))
+	}
+	return j
+}
+
+func decodeStatements(j []jsonStatement) ([]Statement, error) {
+	var statements []Statement
+	for _, js := range j {
+		s, err := decodeStatement(js)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, s)
+	}
+	return statements, nil
+}
+
+func encodeStatement(s Statement) jsonStatement {
+	j := jsonStatement{
+		Start: encodePos(s.Start()),
+		End:   encodePos(s.End()),
+	}
+	if c, ok := s.(interface{ comments() Comments }); ok {
+		j.Leading = encodeComments(c.comments().Leading)
+		j.Trailing = encodeComments(c.comments().Trailing)
+	}
+	block := func(b Block) *jsonBlock {
+		j := encodeBlock(b)
+		return &j
+	}
+	switch x := s.(type) {
+	case Instruction:
+		j.Kind = kindInstruction
+		j.Text, j.Quoted = x.Text, x.quoted
+	case Call:
+		j.Kind = kindCall
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Text, j.Quoted = x.Text, x.quoted
+		j.TextStart = encodePos(x.textStart)
+	case Break:
+		j.Kind = kindBreak
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Text, j.Quoted = x.Text, x.quoted
+		j.TextStart = encodePos(x.textStart)
+	case Return:
+		j.Kind = kindReturn
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Text, j.Quoted = x.Text, x.quoted
+		j.TextStart = encodePos(x.textStart)
+	case Continue:
+		j.Kind = kindContinue
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Text, j.Quoted = x.Text, x.quoted
+		j.TextStart = encodePos(x.textStart)
+	case Exit:
+		j.Kind = kindExit
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Text, j.Quoted = x.Text, x.quoted
+		j.TextStart = encodePos(x.textStart)
+	case BadStatement:
+		j.Kind = kindBad
+		j.Text = x.Text
+	case If:
+		j.Kind = kindIf
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Condition = encodeString(x.Condition)
+		j.TrueText = encodeString(x.TrueText)
+		j.Then = block(x.Then)
+	case IfElse:
+		j.Kind = kindIfElse
+		j.Keywords = encodeKeywords(x.Keyword(), x.ElseKeyword())
+		j.Condition = encodeString(x.Condition)
+		j.TrueText = encodeString(x.TrueText)
+		j.Then = block(x.Then)
+		j.FalseText = encodeString(x.FalseText)
+		j.Else = block(x.Else)
+	case IfChain:
+		j.Kind = kindIfChain
+		j.Keywords = encodeKeywords(x.Keyword(), x.ElseKeyword())
+		j.Condition = encodeString(x.Condition)
+		j.TrueText = encodeString(x.TrueText)
+		j.Then = block(x.Then)
+		j.ElseIfs = []jsonElseIf{}
+		for _, e := range x.ElseIfs {
+			j.ElseIfs = append(j.ElseIfs, jsonElseIf{
+				Keywords:  encodeKeywords(e.Keyword(), e.IfKeyword()),
+				Condition: encodeString(e.Condition),
+				TrueText:  encodeString(e.TrueText),
+				Block:     encodeBlock(e.Block),
+				Start:     encodePos(e.start),
+				End:       encodePos(e.End()),
+			})
+		}
+		if x.HasElse {
+			j.FalseText = encodeString(x.FalseText)
+			j.Else = block(x.Else)
+		}
+	case Try:
+		j.Kind = kindTry
+		j.Keywords = encodeKeywords(x.Keyword(), x.FinallyKeyword())
+		j.Block = block(x.Block)
+		j.Catches = []jsonCatch{}
+		for _, c := range x.Catches {
+			j.Catches = append(j.Catches, jsonCatch{
+				Keywords:  encodeKeywords(c.Keyword()),
+				Exception: encodeString(c.Exception),
+				Block:     encodeBlock(c.Block),
+				Start:     encodePos(c.start),
+				End:       encodePos(c.End()),
+			})
+		}
+		if x.HasFinally {
+			j.Finally = block(x.Finally)
+		}
+	case Switch:
+		j.Kind = kindSwitch
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Subject = encodeString(x.Subject)
+		j.Cases = []jsonCase{}
+		for _, c := range x.Cases {
+			j.Cases = append(j.Cases, jsonCase{
+				Keywords:  encodeKeywords(c.Keyword(), c.DefaultKeyword()),
+				IsDefault: c.IsDefault,
+				Condition: encodeString(c.Condition),
+				Block:     encodeBlock(c.Block),
+				Leading:   encodeComments(c.Leading),
+				Trailing:  encodeComments(c.Trailing),
+				Start:     encodePos(c.start),
+				End:       encodePos(c.End()),
+			})
+		}
+		j.EndComments = encodeComments(x.EndComments)
+	case Parallel:
+		j.Kind = kindParallel
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Blocks = []jsonBlock{}
+		for _, b := range x.Blocks {
+			j.Blocks = append(j.Blocks, encodeBlock(b))
+		}
+		j.EndComments = encodeComments(x.EndComments)
+	case InfiniteLoop:
+		j.Kind = kindInfiniteLoop
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Block = block(x.Block)
+	case While:
+		j.Kind = kindWhile
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Condition = encodeString(x.Condition)
+		j.Block = block(x.Block)
+	case DoWhile:
+		j.Kind = kindDoWhile
+		j.Keywords = encodeKeywords(x.Keyword(), x.WhileKeyword())
+		j.Block = block(x.Block)
+		j.Condition = encodeString(x.Condition)
+	case RepeatUntil:
+		j.Kind = kindRepeatUntil
+		j.Keywords = encodeKeywords(x.Keyword(), x.UntilKeyword())
+		j.Block = block(x.Block)
+		j.Condition = encodeString(x.Condition)
+	case For:
+		j.Kind = kindFor
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Variable = encodeString(x.Variable)
+		j.From = encodeString(x.From)
+		j.To = encodeString(x.To)
+		j.Step = encodeString(x.Step)
+		j.Block = block(x.Block)
+	case Foreach:
+		j.Kind = kindForeach
+		j.Keywords = encodeKeywords(x.Keyword())
+		j.Variable = encodeString(x.Variable)
+		j.Collection = encodeString(x.Collection)
+		j.Block = block(x.Block)
+	}
+	return j
+}
+
+func decodeStatement(j jsonStatement) (Statement, error) {
+	comments := Comments{
+		Leading:  decodeComments(j.Leading),
+		Trailing: decodeComments(j.Trailing),
+	}
+	start, end := decodePos(j.Start), decodePos(j.End)
+	var err error
+	block := func(j *jsonBlock) Block {
+		b, blockErr := decodeBlock(j)
+		if err == nil {
+			err = blockErr
+		}
+		return b
+	}
+
+	var s Statement
+	switch j.Kind {
+	case kindInstruction:
+		s = Instruction{
+			Text:     j.Text,
+			Comments: comments,
+			quoted:   j.Quoted,
+			start:    start,
+			end:      end,
+		}
+	case kindCall:
+		s = Call{
+			Text:      j.Text,
+			Comments:  comments,
+			quoted:    j.Quoted,
+			start:     start,
+			end:       end,
+			textStart: decodePos(j.TextStart),
+		}
+	case kindBreak:
+		s = Break{
+			Text:      j.Text,
+			Comments:  comments,
+			quoted:    j.Quoted,
+			start:     start,
+			end:       end,
+			textStart: decodePos(j.TextStart),
+		}
+	case kindReturn:
+		s = Return{
+			Text:      j.Text,
+			Comments:  comments,
+			quoted:    j.Quoted,
+			start:     start,
+			end:       end,
+			textStart: decodePos(j.TextStart),
+		}
+	case kindContinue:
+		s = Continue{
+			Text:      j.Text,
+			Comments:  comments,
+			quoted:    j.Quoted,
+			start:     start,
+			end:       end,
+			textStart: decodePos(j.TextStart),
+		}
+	case kindExit:
+		s = Exit{
+			Text:      j.Text,
+			Comments:  comments,
+			quoted:    j.Quoted,
+			start:     start,
+			end:       end,
+			textStart: decodePos(j.TextStart),
+		}
+	case kindBad:
+		s = BadStatement{
+			Text:     j.Text,
+			Comments: comments,
+			start:    start,
+			end:      end,
+		}
+	case kindIf:
+		s = If{
+			Condition: decodeString(j.Condition),
+			TrueText:  decodeString(j.TrueText),
+			Then:      block(j.Then),
+			Comments:  comments,
+			start:     start,
+		}
+	case kindIfElse:
+		s = IfElse{
+			Condition: decodeString(j.Condition),
+			TrueText:  decodeString(j.TrueText),
+			Then:      block(j.Then),
+			FalseText: decodeString(j.FalseText),
+			Else:      block(j.Else),
+			Comments:  comments,
+			start:     start,
+			elseStart: decodeKeyword(j.Keywords, "else"),
+		}
+	case kindIfChain:
+		c := IfChain{
+			Condition: decodeString(j.Condition),
+			TrueText:  decodeString(j.TrueText),
+			Then:      block(j.Then),
+			HasElse:   j.Else != nil,
+			FalseText: decodeString(j.FalseText),
+			Else:      block(j.Else),
+			Comments:  comments,
+			start:     start,
+			elseStart: decodeKeyword(j.Keywords, "else"),
+		}
+		for i := range j.ElseIfs {
+			e := j.ElseIfs[i]
+			c.ElseIfs = append(c.ElseIfs, ElseIf{
+				Condition: decodeString(e.Condition),
+				TrueText:  decodeString(e.TrueText),
+				Block:     block(&e.Block),
+				start:     decodePos(e.Start),
+				ifStart:   decodeKeyword(e.Keywords, "if"),
+			})
+		}
+		s = c
+	case kindTry:
+		t := Try{
+			Block:        block(j.Block),
+			HasFinally:   j.Finally != nil,
+			Finally:      block(j.Finally),
+			Comments:     comments,
+			start:        start,
+			finallyStart: decodeKeyword(j.Keywords, "finally"),
+		}
+		for i := range j.Catches {
+			c := j.Catches[i]
+			t.Catches = append(t.Catches, Catch{
+				Exception: decodeString(c.Exception),
+				Block:     block(&c.Block),
+				start:     decodePos(c.Start),
+			})
+		}
+		s = t
+	case kindSwitch:
+		sw := Switch{
+			Subject:     decodeString(j.Subject),
+			EndComments: decodeComments(j.EndComments),
+			Comments:    comments,
+			start:       start,
+			end:         end,
+		}
+		for _, c := range j.Cases {
+			sw.Cases = append(sw.Cases, SwitchCase{
+				IsDefault: c.IsDefault,
+				Condition: decodeString(c.Condition),
+				Block:     block(&c.Block),
+				Comments: Comments{
+					Leading:  decodeComments(c.Leading),
+					Trailing: decodeComments(c.Trailing),
+				},
+				start:        decodePos(c.Start),
+				defaultStart: decodeKeyword(c.Keywords, "default"),
+			})
+		}
+		s = sw
+	case kindParallel:
+		p := Parallel{
+			EndComments: decodeComments(j.EndComments),
+			Comments:    comments,
+			start:       start,
+			end:         end,
+		}
+		for i := range j.Blocks {
+			p.Blocks = append(p.Blocks, block(&j.Blocks[i]))
+		}
+		s = p
+	case kindInfiniteLoop:
+		s = InfiniteLoop{
+			Block:    block(j.Block),
+			Comments: comments,
+			start:    start,
+		}
+	case kindWhile:
+		s = While{
+			Condition: decodeString(j.Condition),
+			Block:     block(j.Block),
+			Comments:  comments,
+			start:     start,
+		}
+	case kindDoWhile:
+		s = DoWhile{
+			Block:      block(j.Block),
+			Condition:  decodeString(j.Condition),
+			Comments:   comments,
+			start:      start,
+			whileStart: decodeKeyword(j.Keywords, "while"),
+		}
+	case kindRepeatUntil:
+		s = RepeatUntil{
+			Block:      block(j.Block),
+			Condition:  decodeString(j.Condition),
+			Comments:   comments,
+			start:      start,
+			untilStart: decodeKeyword(j.Keywords, "until"),
+		}
+	case kindFor:
+		s = For{
+			Variable: decodeString(j.Variable),
+			From:     decodeString(j.From),
+			To:       decodeString(j.To),
+			Step:     decodeString(j.Step),
+			Block:    block(j.Block),
+			Comments: comments,
+			start:    start,
+		}
+	case kindForeach:
+		s = Foreach{
+			Variable:   decodeString(j.Variable),
+			Collection: decodeString(j.Collection),
+			Block:      block(j.Block),
+			Comments:   comments,
+			start:      start,
+		}
+	default:
+		return nil, fmt.Errorf("unknown statement kind %q", j.Kind)
+	}
+	return s, err
+}
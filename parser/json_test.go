@@ -0,0 +1,116 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/gonutz/check"
+)
+
+func TestJSONRoundTripIsLossless(t *testing.T) {
+	s, err := ParseString(`// header
+title "all" // title
+"a\n\"b\"" // trailing
+call "c"
+break ""
+return "x"
+continue ""
+exit "error"
+if "d" { "e" }
+if "f" "yes" {} else "no" { "g" }
+if "x1" { "y1" } else if "x2" "T" {} else if "x3" { "y3" } else "F" { "y4" }
+if "x5" {} else if "x6" {}
+switch "h" {
+	// before case
+	case "i" {}
+	case default "j" { "k" }
+	// end of switch
+}
+parallel { {} { "l" } }
+while { "m" }
+while "n" { /* end of block */ }
+do { "o" } while "p"
+repeat { "v" } until "w"
+try { "s" } catch "IOError e" { "t" } catch {} finally { "u" }
+try {} catch "E" {}
+try /*a*/ {} /*b*/ catch {} // c
+finally {}
+parallel { {} // d
+{} }
+for "i" "1" "10" "step 2" { "q" }
+for "j" "10" "1" {}
+foreach "x" "in xs" { "r" }
+// end`)
+	check.Eq(t, err, nil)
+
+	data, err := json.Marshal(s)
+	check.Eq(t, err, nil)
+	var decoded Structogram
+	check.Eq(t, json.Unmarshal(data, &decoded), nil)
+	check.Eq(t, &decoded, s)
+}
+
+func TestJSONRoundTripOfBuiltStructogram(t *testing.T) {
+	s := NewBuilder("").
+		IfElse("a", nil, func(b *Builder) { b.Instr("b") }).
+		Switch("c", func(s *SwitchBuilder) { s.Default("", nil) }).
+		Structogram()
+	data, err := json.Marshal(s)
+	check.Eq(t, err, nil)
+	var decoded Structogram
+	check.Eq(t, json.Unmarshal(data, &decoded), nil)
+	check.Eq(t, &decoded, s)
+}
+
+func TestJSONEncoding(t *testing.T) {
+	s, err := ParseString(`while "x" { "y" }`)
+	check.Eq(t, err, nil)
+	data, err := json.Marshal(s)
+	check.Eq(t, err, nil)
+	check.Eq(t, string(data), `{"statements":[{"kind":"while",`+
+		`"keywords":[{"text":"while","start":{"line":1,"col":1,"offset":0},"end":{"line":1,"col":6,"offset":5}}],`+
+		`"condition":{"text":"x","quoted":"\"x\"","start":{"line":1,"col":7,"offset":6},"end":{"line":1,"col":10,"offset":9}},`+
+		`"block":{"statements":[{"kind":"instruction","text":"y","quoted":"\"y\"","start":{"line":1,"col":13,"offset":12},"end":{"line":1,"col":16,"offset":15}}],`+
+		`"start":{"line":1,"col":11,"offset":10},"end":{"line":1,"col":18,"offset":17}},`+
+		`"start":{"line":1,"col":1,"offset":0},"end":{"line":1,"col":18,"offset":17}}]}`)
+}
+
+func TestJSONWithoutQuotedStringsFormatsWithQuotes(t *testing.T) {
+	var s Structogram
+	err := json.Unmarshal([]byte(`{"statements":[{"kind":"call","text":"say \"hi\""}]}`), &s)
+	check.Eq(t, err, nil)
+	check.Eq(t, s.Statements, []Statement{Call{Text: `say "hi"`}})
+	formatted, err := FormatString(`call "say \"hi\""`)
+	check.Eq(t, err, nil)
+	var buf bytes.Buffer
+	check.Eq(t, Fprint(&buf, &s), nil)
+	check.Eq(t, buf.String(), formatted)
+}
+
+func TestJSONWithUnknownKindIsAnError(t *testing.T) {
+	var s Structogram
+	err := json.Unmarshal([]byte(`{"statements":[{"kind":"goto"}]}`), &s)
+	check.Eq(t, err.Error(), `unknown statement kind "goto"`)
+}
+
+func TestJSONSchemaCoversAllKinds(t *testing.T) {
+	var schema struct {
+		Defs struct {
+			Statement struct {
+				Properties struct {
+					Kind struct {
+						Enum []string
+					}
+				}
+			}
+		} `json:"$defs"`
+	}
+	check.Eq(t, json.Unmarshal([]byte(JSONSchema), &schema), nil)
+	kinds := schema.Defs.Statement.Properties.Kind.Enum
+	check.Eq(t, len(kinds), 19)
+	for _, kind := range kinds {
+		_, err := decodeStatement(jsonStatement{Kind: kind})
+		check.Eq(t, err, nil, kind)
+	}
+}
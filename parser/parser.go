@@ -1,67 +1,198 @@
 package parser
 
 import (
-	"errors"
 	"strings"
+	"unicode"
 )
 
 func ParseString(code string) (*Structogram, error) {
-	var s Structogram
-
-	tokens, err := tokenize(code)
-	if err != nil {
-		return nil, errors.New("parse error: " + err.Error())
+	s, errs := ParsePartial(code)
+	// We do not want to return a half-backed structogram so we return either
+	// nil and the first error or the strucogram and nil.
+	if len(errs) > 0 {
+		return nil, errs[0]
 	}
+	return s, nil
+}
+
+// ParsePartial is like ParseString but it does not stop at the first error.
+// Invalid code is skipped up to the next statement keyword, string or the end
+// of its block and is replaced by a BadStatement. The returned Structogram is
+// never nil, errs contains all errors, sorted by position.
+func ParsePartial(code string) (s *Structogram, errs ErrorList) {
+	s = &Structogram{}
+
+	tokens, errs := tokenize(code)
 
 	position := func() Pos {
-		return Pos{Col: tokens[0].col, Line: tokens[0].line}
+		return tokens[0].start()
 	}
 	endPosition := func() Pos {
-		if len(tokens) >= 2 {
-			return Pos{Col: tokens[1].col, Line: tokens[1].line}
-		}
-		return Pos{}
+		return tokens[0].end()
+	}
+	// pending are the comments that were skipped but are not yet attached to
+	// a node. Comments in the same line as the token before them are trailing
+	// comments, lastLine is the line where that token ends.
+	type pendingComment struct {
+		Comment
+		trailing bool
 	}
+	var pending []pendingComment
+	lastLine := 0
+	// lastEnd is where the last token that was not space or a comment ends.
+	var lastEnd Pos
 	skipSpace := func() {
-		for len(tokens) > 0 && tokens[0].typ == tokenSpace {
+		for len(tokens) > 0 &&
+			(tokens[0].typ == tokenSpace || tokens[0].typ == tokenComment) {
+			if tokens[0].typ == tokenComment {
+				pending = append(pending, pendingComment{
+					Comment:  newComment(tokens[0]),
+					trailing: tokens[0].line == lastLine,
+				})
+			}
 			tokens = tokens[1:]
 		}
 	}
 	skip := func() {
+		lastEnd = tokens[0].end()
+		lastLine = lastEnd.Line
 		tokens = tokens[1:]
 		skipSpace()
 	}
+	// takeComments attaches all pending comments to the caller.
+	takeComments := func() []Comment {
+		var comments []Comment
+		for _, c := range pending {
+			comments = append(comments, c.Comment)
+		}
+		pending = nil
+		return comments
+	}
+	// takeTrailing attaches the pending comments that trail the last token to
+	// the caller.
+	takeTrailing := func() []Comment {
+		var comments []Comment
+		for len(pending) > 0 && pending[0].trailing {
+			comments = append(comments, pending[0].Comment)
+			pending = pending[1:]
+		}
+		return comments
+	}
 	sees := func(typ tokenType) bool {
 		return len(tokens) > 0 && tokens[0].typ == typ
 	}
 	seesID := func(id string) bool {
 		return sees(tokenID) && tokens[0].text == id
 	}
-	eatString := func() string {
-		if sees(tokenString) {
-			s := tokens[0].text
-			skip()
-			return escapeString(s)
+	seesKeyword := func(keywords []string) bool {
+		for _, keyword := range keywords {
+			if seesID(keyword) {
+				return true
+			}
 		}
-		// TODO Have position info in error messages.
-		err = errors.New("parse error: string expected")
-		return ""
+		return false
+	}
+	// report adds a parse error unless there already is one in the same line,
+	// follow-up errors are usually caused by the first one.
+	lastErrorLine := 0
+	report := func(err *Error) {
+		if err.Start.Line != lastErrorLine {
+			errs = append(errs, err)
+			lastErrorLine = err.Start.Line
+		}
+	}
+	// fail reports that the current token is not what we expected.
+	fail := func(expected string) {
+		report(&Error{
+			Start:    position(),
+			End:      tokens[0].end(),
+			Expected: expected,
+			Found:    tokens[0].describe(),
+		})
+	}
+	// failStatement is like fail for places where a statement could have been
+	// given instead of what we expected. If the current token is a misspelled
+	// keyword, the error suggests the right spelling.
+	failStatement := func(expected string, keywords []string) {
+		err := &Error{
+			Start:    position(),
+			End:      tokens[0].end(),
+			Expected: expected,
+			Found:    tokens[0].describe(),
+		}
+		if sees(tokenID) {
+			err.Suggestion = suggestKeyword(tokens[0].text, keywords)
+		}
+		report(err)
+	}
+	// skipTo skips invalid code up to the next of the given keywords, the next
+	// string, the end of the current block or the end of input. Blocks in the
+	// invalid code are skipped as a whole. The skipped code is returned as a
+	// BadStatement.
+	skipTo := func(keywords []string) BadStatement {
+		bad := BadStatement{start: position()}
+		var text strings.Builder
+		depth := 0
+		for first := true; !sees(tokenEOF); first = false {
+			if !first && depth == 0 &&
+				(sees('}') || sees(tokenString) || seesKeyword(keywords)) {
+				break
+			}
+			if sees('{') {
+				depth++
+			} else if sees('}') && depth > 0 {
+				depth--
+			}
+			if !sees(tokenSpace) {
+				bad.end = tokens[0].end()
+			}
+			text.WriteString(tokens[0].text)
+			tokens = tokens[1:]
+		}
+		bad.Text = strings.TrimSpace(text.String())
+		return bad
+	}
+	// eatStringNode eats a string and returns it with its position and quoted
+	// text. A missing string is empty and has no quoted text, it starts and
+	// ends right after the code before it.
+	eatStringNode := func() String {
+		if !sees(tokenString) {
+			fail(tokenString.String())
+			return String{start: lastEnd, end: lastEnd}
+		}
+		s := String{
+			Text:   escapeString(tokens[0].text),
+			quoted: tokens[0].text,
+			start:  position(),
+			end:    endPosition(),
+		}
+		skip()
+		return s
 	}
 	eat := func(typ tokenType) {
 		if sees(typ) {
 			skip()
 		} else {
-			err = errors.New("parse error: " + typ.String() + " expected")
+			fail(typ.String())
 		}
 	}
 
-	var parseStatements func() []Statement
+	var parseStatements func(inBlock bool) []Statement
 
 	parseBlock := func() Block {
 		var b Block
+		b.Leading = takeComments()
 		b.start = position()
-		eat('{')
-		b.Statements = parseStatements()
+		if !sees('{') {
+			// Without the opening brace we do not know where the block ends,
+			// we leave it empty and parse the rest of the code after it.
+			fail(tokenType('{').String())
+			b.end = b.start
+			return b
+		}
+		skip()
+		b.Statements = parseStatements(true)
+		b.EndComments = takeComments()
 		b.end = endPosition()
 		eat('}')
 		return b
@@ -69,38 +200,62 @@ func ParseString(code string) (*Structogram, error) {
 
 	parseStatement := func() (Statement, bool) {
 		if sees(tokenString) {
-			var i Instruction
-			i.start = position()
-			i.end = endPosition()
-			i.quoted = tokens[0].text
-			i.Text = eatString()
-			return i, true
+			text := eatStringNode()
+			return Instruction{
+				Text:   text.Text,
+				quoted: text.quoted,
+				start:  text.start,
+				end:    text.end,
+			}, true
 		} else if seesID("if") {
 			ifStart := position()
 			skip()
-			var condition, trueText String
-			condition.start = position()
-			condition.end = endPosition()
-			condition.quoted = tokens[0].text
-			condition.Text = eatString()
+			condition := eatStringNode()
+			var trueText String
 			if sees(tokenString) {
-				trueText.start = position()
-				trueText.end = endPosition()
-				trueText.quoted = tokens[0].text
-				trueText.Text = eatString()
+				trueText = eatStringNode()
 			}
 			then := parseBlock()
-			if seesID("else") {
+			var elseIfs []ElseIf
+			// last is the block before the next else, the comments up to the
+			// else belong to it.
+			last := &then
+			for seesID("else") {
+				last.Trailing = takeComments()
+				elseStart := position()
 				skip()
+				if seesID("if") {
+					e := ElseIf{start: elseStart, ifStart: position()}
+					skip()
+					e.Condition = eatStringNode()
+					if sees(tokenString) {
+						e.TrueText = eatStringNode()
+					}
+					e.Block = parseBlock()
+					elseIfs = append(elseIfs, e)
+					last = &elseIfs[len(elseIfs)-1].Block
+					continue
+				}
 				var falseText String
 				if sees(tokenString) {
-					falseText.start = position()
-					falseText.end = endPosition()
-					falseText.quoted = tokens[0].text
-					falseText.Text = eatString()
+					falseText = eatStringNode()
+				}
+				if len(elseIfs) > 0 {
+					return IfChain{
+						start:     ifStart,
+						elseStart: elseStart,
+						Condition: condition,
+						TrueText:  trueText,
+						Then:      then,
+						ElseIfs:   elseIfs,
+						HasElse:   true,
+						FalseText: falseText,
+						Else:      parseBlock(),
+					}, true
 				}
 				return IfElse{
 					start:     ifStart,
+					elseStart: elseStart,
 					Condition: condition,
 					TrueText:  trueText,
 					Then:      then,
@@ -108,6 +263,15 @@ func ParseString(code string) (*Structogram, error) {
 					Else:      parseBlock(),
 				}, true
 			}
+			if len(elseIfs) > 0 {
+				return IfChain{
+					start:     ifStart,
+					Condition: condition,
+					TrueText:  trueText,
+					Then:      then,
+					ElseIfs:   elseIfs,
+				}, true
+			}
 			return If{
 				start:     ifStart,
 				Condition: condition,
@@ -118,32 +282,38 @@ func ParseString(code string) (*Structogram, error) {
 			var switchStmt Switch
 			switchStmt.start = position()
 			skip()
-			switchStmt.Subject.start = position()
-			switchStmt.Subject.end = endPosition()
-			switchStmt.Subject.quoted = tokens[0].text
-			switchStmt.Subject.Text = eatString()
-			eat('{')
-			for seesID("case") {
-				skip()
+			switchStmt.Subject = eatStringNode()
+			if !sees('{') {
+				fail(tokenType('{').String())
+				switchStmt.end = switchStmt.Subject.end
+				return switchStmt, true
+			}
+			skip()
+			for !sees('}') && !sees(tokenEOF) {
+				if !seesID("case") {
+					failStatement(tokenType('}').String(), []string{"case"})
+					skipTo([]string{"case"})
+					continue
+				}
 				var c SwitchCase
+				c.Leading = takeComments()
+				c.start = position()
+				skip()
 				if seesID("default") {
+					c.defaultStart = position()
 					skip()
 					c.IsDefault = true
 					if sees(tokenString) {
-						c.Condition.start = position()
-						c.Condition.end = endPosition()
-						c.Condition.quoted = tokens[0].text
-						c.Condition.Text = eatString()
+						c.Condition = eatStringNode()
 					}
 				} else {
-					c.Condition.start = position()
-					c.Condition.end = endPosition()
-					c.Condition.quoted = tokens[0].text
-					c.Condition.Text = eatString()
+					c.Condition = eatStringNode()
 				}
 				c.Block = parseBlock()
+				c.Trailing = takeTrailing()
 				switchStmt.Cases = append(switchStmt.Cases, c)
 			}
+			switchStmt.EndComments = takeComments()
 			switchStmt.end = endPosition()
 			eat('}')
 			return switchStmt, true
@@ -153,10 +323,7 @@ func ParseString(code string) (*Structogram, error) {
 			if sees(tokenString) {
 				var w While
 				w.start = start
-				w.Condition.start = position()
-				w.Condition.end = endPosition()
-				w.Condition.quoted = tokens[0].text
-				w.Condition.Text = eatString()
+				w.Condition = eatStringNode()
 				w.Block = parseBlock()
 				return w, true
 			} else {
@@ -171,40 +338,144 @@ func ParseString(code string) (*Structogram, error) {
 			skip()
 			do.Block = parseBlock()
 			if seesID("while") {
+				do.Block.Trailing = takeComments()
+				do.whileStart = position()
 				skip()
 			} else {
-				err = errors.New("keyword 'while' expected at the end of do-while loop")
-				return nil, false
+				fail("keyword 'while' at the end of do-while loop")
 			}
-			do.Condition.start = position()
-			do.Condition.end = endPosition()
-			do.Condition.quoted = tokens[0].text
-			do.Condition.Text = eatString()
+			do.Condition = eatStringNode()
 			return do, true
+		} else if seesID("repeat") {
+			var r RepeatUntil
+			r.start = position()
+			skip()
+			r.Block = parseBlock()
+			if seesID("until") {
+				r.Block.Trailing = takeComments()
+				r.untilStart = position()
+				skip()
+			} else {
+				fail("keyword 'until' at the end of repeat-until loop")
+			}
+			r.Condition = eatStringNode()
+			return r, true
+		} else if seesID("for") {
+			var f For
+			f.start = position()
+			skip()
+			f.Variable = eatStringNode()
+			f.From = eatStringNode()
+			f.To = eatStringNode()
+			if sees(tokenString) {
+				f.Step = eatStringNode()
+			}
+			f.Block = parseBlock()
+			return f, true
+		} else if seesID("foreach") {
+			var f Foreach
+			f.start = position()
+			skip()
+			f.Variable = eatStringNode()
+			f.Collection = eatStringNode()
+			f.Block = parseBlock()
+			return f, true
+		} else if seesID("try") {
+			var t Try
+			t.start = position()
+			skip()
+			t.Block = parseBlock()
+			if !seesID("catch") {
+				fail("keyword 'catch' after try block")
+			}
+			// last is the block before the next catch or finally, the
+			// comments up to that keyword belong to it.
+			last := &t.Block
+			for seesID("catch") {
+				last.Trailing = takeComments()
+				var c Catch
+				c.start = position()
+				skip()
+				if sees(tokenString) {
+					c.Exception = eatStringNode()
+				}
+				c.Block = parseBlock()
+				t.Catches = append(t.Catches, c)
+				last = &t.Catches[len(t.Catches)-1].Block
+			}
+			if seesID("finally") {
+				last.Trailing = takeComments()
+				t.finallyStart = position()
+				skip()
+				t.HasFinally = true
+				t.Finally = parseBlock()
+			}
+			return t, true
 		} else if seesID("break") {
 			var b Break
 			b.start = position()
 			skip()
-			b.quoted = tokens[0].text
-			b.end = endPosition()
-			b.Text = eatString()
+			text := eatStringNode()
+			b.textStart, b.end = text.start, text.end
+			b.quoted, b.Text = text.quoted, text.Text
 			return b, true
+		} else if seesID("return") {
+			var r Return
+			r.start = position()
+			skip()
+			text := eatStringNode()
+			r.textStart, r.end = text.start, text.end
+			r.quoted, r.Text = text.quoted, text.Text
+			return r, true
+		} else if seesID("continue") {
+			var c Continue
+			c.start = position()
+			skip()
+			text := eatStringNode()
+			c.textStart, c.end = text.start, text.end
+			c.quoted, c.Text = text.quoted, text.Text
+			return c, true
+		} else if seesID("exit") {
+			var e Exit
+			e.start = position()
+			skip()
+			text := eatStringNode()
+			e.textStart, e.end = text.start, text.end
+			e.quoted, e.Text = text.quoted, text.Text
+			return e, true
 		} else if seesID("call") {
 			var c Call
 			c.start = position()
 			skip()
-			c.quoted = tokens[0].text
-			c.end = endPosition()
-			c.Text = eatString()
+			text := eatStringNode()
+			c.textStart, c.end = text.start, text.end
+			c.quoted, c.Text = text.quoted, text.Text
 			return c, true
 		} else if seesID("parallel") {
 			var p Parallel
 			p.start = position()
 			skip()
-			eat('{')
-			for sees('{') {
-				p.Blocks = append(p.Blocks, parseBlock())
+			if !sees('{') {
+				fail(tokenType('{').String())
+				p.end = p.start
+				return p, true
+			}
+			skip()
+			for !sees('}') && !sees(tokenEOF) {
+				if !sees('{') {
+					fail(tokenType('}').String())
+					skipTo(nil)
+					continue
+				}
+				b := parseBlock()
+				if !sees('}') {
+					// Comments in the same line as the block belong to it, the
+					// others belong to the next block.
+					b.Trailing = takeTrailing()
+				}
+				p.Blocks = append(p.Blocks, b)
 			}
+			p.EndComments = takeComments()
 			p.end = endPosition()
 			eat('}')
 			return p, true
@@ -212,13 +483,27 @@ func ParseString(code string) (*Structogram, error) {
 		return nil, false
 	}
 
-	parseStatements = func() []Statement {
+	// parseStatements parses statements up to the end of input or, inBlock,
+	// up to the closing '}'.
+	parseStatements = func(inBlock bool) []Statement {
+		expected := "statement"
+		if inBlock {
+			expected = tokenType('}').String()
+		}
 		var all []Statement
-		for {
+		for !sees(tokenEOF) && !(inBlock && sees('}')) {
+			leading := takeComments()
 			if s, ok := parseStatement(); ok {
-				all = append(all, s)
+				all = append(all, withComments(s, Comments{
+					Leading:  leading,
+					Trailing: takeTrailing(),
+				}))
 			} else {
-				break
+				failStatement(expected, statementKeywords)
+				bad := skipTo(statementKeywords)
+				bad.Leading = leading
+				bad.Trailing = takeTrailing()
+				all = append(all, bad)
 			}
 		}
 		return all
@@ -228,28 +513,101 @@ func ParseString(code string) (*Structogram, error) {
 	skipSpace()
 	// Parse optional title.
 	if seesID("title") {
+		s.Leading = takeComments()
+		s.titleStart = position()
 		skip()
-		s.Title.start = position()
-		s.Title.end = endPosition()
-		s.Title.quoted = tokens[0].text
-		s.Title.Text = eatString()
+		s.Title = eatStringNode()
+		s.Trailing = takeTrailing()
+	}
+	// Parse code. Everything up to the end of input has to be statements, we
+	// do not want to silently ignore anything.
+	s.Statements = parseStatements(false)
+	s.EndComments = takeComments()
+
+	errs.sort()
+	return s, errs
+}
+
+// newComment creates a comment from a comment token. Line comments do not
+// include trailing white space.
+func newComment(t token) Comment {
+	t.text = strings.TrimRightFunc(t.text, unicode.IsSpace)
+	return Comment{
+		Text:  t.text,
+		start: t.start(),
+		end:   t.end(),
 	}
-	// Parse code.
-	s.Statements = parseStatements()
+}
 
-	// We might have set the err variable and if we have, we do not want to
-	// return a half-backed structogram so we return either nil and the error or
-	// the strucogram and nil.
-	if err != nil {
-		return nil, err
+// withComments returns a copy of the statement with the given comments.
+func withComments(s Statement, c Comments) Statement {
+	switch x := s.(type) {
+	case Instruction:
+		x.Comments = c
+		return x
+	case Break:
+		x.Comments = c
+		return x
+	case Return:
+		x.Comments = c
+		return x
+	case Continue:
+		x.Comments = c
+		return x
+	case Exit:
+		x.Comments = c
+		return x
+	case Call:
+		x.Comments = c
+		return x
+	case If:
+		x.Comments = c
+		return x
+	case IfElse:
+		x.Comments = c
+		return x
+	case IfChain:
+		x.Comments = c
+		return x
+	case Try:
+		x.Comments = c
+		return x
+	case Switch:
+		x.Comments = c
+		return x
+	case Parallel:
+		x.Comments = c
+		return x
+	case InfiniteLoop:
+		x.Comments = c
+		return x
+	case While:
+		x.Comments = c
+		return x
+	case DoWhile:
+		x.Comments = c
+		return x
+	case RepeatUntil:
+		x.Comments = c
+		return x
+	case For:
+		x.Comments = c
+		return x
+	case Foreach:
+		x.Comments = c
+		return x
+	case BadStatement:
+		x.Comments = c
+		return x
 	}
-	return &s, nil
+	return s
 }
 
 func escapeString(s string) string {
 	s = s[1 : len(s)-1] // Trim '"' at front and back.
-	s = strings.Replace(s, `\n`, "\n", -1)
-	s = strings.Replace(s, `\\`, "\\", -1)
-	s = strings.Replace(s, `\"`, "\"", -1)
-	return s
+	return unquoter.Replace(s)
 }
+
+// unquoter replaces escape sequences in a single pass so that e.g. "\\n" is
+// a backslash followed by an n and not by a line break.
+var unquoter = strings.NewReplacer(`\n`, "\n", `\\`, "\\", `\"`, "\"")
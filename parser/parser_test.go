@@ -7,8 +7,8 @@ import (
 )
 
 func TestTokenization(t *testing.T) {
-	tokens, err := tokenize(`title{}"" "î" "\n\\\""` + "\n\tNextLine")
-	check.Eq(t, err, nil)
+	tokens, errs := tokenize(`title{}"" "î" "\n\\\""` + "\n\tNextLine")
+	check.Eq(t, len(errs), 0)
 	tok := func(want token) {
 		t.Helper()
 		check.Eq(t, tokens[0], want)
@@ -16,23 +16,23 @@ func TestTokenization(t *testing.T) {
 	}
 
 	tok(token{typ: tokenID, text: "title", col: 1, line: 1})
-	tok(token{typ: '{', text: "{", col: 6, line: 1})
-	tok(token{typ: '}', text: "}", col: 7, line: 1})
-	tok(token{typ: tokenString, text: `""`, col: 8, line: 1})
-	tok(token{typ: tokenSpace, text: " ", col: 10, line: 1})
-	tok(token{typ: tokenString, text: `"î"`, col: 11, line: 1})
-	tok(token{typ: tokenSpace, text: " ", col: 14, line: 1})
-	tok(token{typ: tokenString, text: `"\n\\\""`, col: 15, line: 1})
-	tok(token{typ: tokenSpace, text: "\n\t", col: 23, line: 1})
-	tok(token{typ: tokenID, text: "NextLine", col: 2, line: 2})
-	tok(token{typ: tokenEOF, text: "", col: 10, line: 2})
+	tok(token{typ: '{', text: "{", col: 6, line: 1, offset: 5})
+	tok(token{typ: '}', text: "}", col: 7, line: 1, offset: 6})
+	tok(token{typ: tokenString, text: `""`, col: 8, line: 1, offset: 7})
+	tok(token{typ: tokenSpace, text: " ", col: 10, line: 1, offset: 9})
+	tok(token{typ: tokenString, text: `"î"`, col: 11, line: 1, offset: 10})
+	tok(token{typ: tokenSpace, text: " ", col: 14, line: 1, offset: 14})
+	tok(token{typ: tokenString, text: `"\n\\\""`, col: 15, line: 1, offset: 15})
+	tok(token{typ: tokenSpace, text: "\n\t", col: 23, line: 1, offset: 23})
+	tok(token{typ: tokenID, text: "NextLine", col: 2, line: 2, offset: 25})
+	tok(token{typ: tokenEOF, text: "", col: 10, line: 2, offset: 33})
 
 	check.Eq(t, len(tokens), 0) // All tokens checked off the list.
 }
 
 func TestTokenizingEscapeSequences(t *testing.T) {
-	tokens, err := tokenize(`"quote:\" backslash:\\ line-break:\n"`)
-	check.Eq(t, err, nil)
+	tokens, errs := tokenize(`"quote:\" backslash:\\ line-break:\n"`)
+	check.Eq(t, len(errs), 0)
 	check.Eq(t, len(tokens), 2)
 	check.Eq(t, tokens[0].typ, tokenString)
 	check.Eq(t, tokens[0].text, `"quote:\" backslash:\\ line-break:\n"`)
@@ -48,23 +48,29 @@ func TestEmptyStringYieldsEmptyStructogram(t *testing.T) {
 func TestTitleComesFirst(t *testing.T) {
 	s, err := ParseString(`title "the title" `)
 	check.Eq(t, err, nil)
-	check.Eq(t, s, &Structogram{Title: String{
-		Text:   "the title",
-		quoted: `"the title"`,
-		start:  Pos{Col: 7, Line: 1},
-		end:    Pos{Col: 18, Line: 1},
-	}})
+	check.Eq(t, s, &Structogram{
+		titleStart: Pos{Col: 1, Line: 1, Offset: 0},
+		Title: String{
+			Text:   "the title",
+			quoted: `"the title"`,
+			start:  Pos{Col: 7, Line: 1, Offset: 6},
+			end:    Pos{Col: 18, Line: 1, Offset: 17},
+		},
+	})
 }
 
 func TestTitleStringIsEscaped(t *testing.T) {
 	s, err := ParseString(`title "quote:\" backslash:\\ line-break:\n"`)
 	check.Eq(t, err, nil)
-	check.Eq(t, s, &Structogram{Title: String{
-		Text:   "quote:\" backslash:\\ line-break:\n",
-		quoted: `"quote:\" backslash:\\ line-break:\n"`,
-		start:  Pos{Col: 7, Line: 1},
-		end:    Pos{Col: 44, Line: 1},
-	}})
+	check.Eq(t, s, &Structogram{
+		titleStart: Pos{Col: 1, Line: 1, Offset: 0},
+		Title: String{
+			Text:   "quote:\" backslash:\\ line-break:\n",
+			quoted: `"quote:\" backslash:\\ line-break:\n"`,
+			start:  Pos{Col: 7, Line: 1, Offset: 6},
+			end:    Pos{Col: 44, Line: 1, Offset: 43},
+		},
+	})
 }
 
 func TestRegularInstructionsAreJustStrings(t *testing.T) {
@@ -74,8 +80,8 @@ func TestRegularInstructionsAreJustStrings(t *testing.T) {
 		Instruction{
 			Text:   "instruction",
 			quoted: `"instruction"`,
-			start:  Pos{Col: 1, Line: 1},
-			end:    Pos{Col: 14, Line: 1},
+			start:  Pos{Col: 1, Line: 1, Offset: 0},
+			end:    Pos{Col: 14, Line: 1, Offset: 13},
 		},
 	}})
 }
@@ -90,34 +96,34 @@ if "condition" {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		If{
-			start: Pos{Col: 1, Line: 2},
+			start: Pos{Col: 1, Line: 2, Offset: 1},
 			Condition: String{
 				Text:   "condition",
 				quoted: `"condition"`,
-				start:  Pos{Col: 4, Line: 2},
-				end:    Pos{Col: 15, Line: 2},
+				start:  Pos{Col: 4, Line: 2, Offset: 4},
+				end:    Pos{Col: 15, Line: 2, Offset: 15},
 			},
 			Then: Block{
-				start: Pos{Col: 16, Line: 2},
-				end:   Pos{Col: 2, Line: 5},
+				start: Pos{Col: 16, Line: 2, Offset: 16},
+				end:   Pos{Col: 2, Line: 5, Offset: 42},
 				Statements: []Statement{
 					Instruction{
 						Text:   "do this",
 						quoted: `"do this"`,
-						start:  Pos{Col: 2, Line: 3},
-						end:    Pos{Col: 11, Line: 3},
+						start:  Pos{Col: 2, Line: 3, Offset: 19},
+						end:    Pos{Col: 11, Line: 3, Offset: 28},
 					},
 					Instruction{
 						Text:   "and that",
 						quoted: `"and that"`,
-						start:  Pos{Col: 2, Line: 4},
-						end:    Pos{Col: 12, Line: 4},
+						start:  Pos{Col: 2, Line: 4, Offset: 30},
+						end:    Pos{Col: 12, Line: 4, Offset: 40},
 					},
 				},
 			},
 		},
 	}})
-	check.Eq(t, s.Statements[0].End(), Pos{Col: 2, Line: 5})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 2, Line: 5, Offset: 42})
 }
 
 func TestIfCanHaveTrueText(t *testing.T) {
@@ -125,26 +131,26 @@ func TestIfCanHaveTrueText(t *testing.T) {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		If{
-			start: Pos{Col: 1, Line: 1},
+			start: Pos{Col: 1, Line: 1, Offset: 0},
 			Condition: String{
 				Text:   "condition",
 				quoted: `"condition"`,
-				start:  Pos{Col: 4, Line: 1},
-				end:    Pos{Col: 15, Line: 1},
+				start:  Pos{Col: 4, Line: 1, Offset: 3},
+				end:    Pos{Col: 15, Line: 1, Offset: 14},
 			},
 			TrueText: String{
 				Text:   "TRUE",
 				quoted: `"TRUE"`,
-				start:  Pos{Col: 16, Line: 1},
-				end:    Pos{Col: 22, Line: 1},
+				start:  Pos{Col: 16, Line: 1, Offset: 15},
+				end:    Pos{Col: 22, Line: 1, Offset: 21},
 			},
 			Then: Block{
-				start: Pos{Col: 23, Line: 1},
-				end:   Pos{Col: 25, Line: 1},
+				start: Pos{Col: 23, Line: 1, Offset: 22},
+				end:   Pos{Col: 25, Line: 1, Offset: 24},
 			},
 		},
 	}})
-	check.Eq(t, s.Statements[0].End(), Pos{Col: 25, Line: 1})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 25, Line: 1, Offset: 24})
 }
 
 func TestIfElseHasBothBlocks(t *testing.T) {
@@ -158,40 +164,41 @@ if "false" {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		IfElse{
-			start: Pos{Col: 1, Line: 2},
+			start:     Pos{Col: 1, Line: 2, Offset: 1},
+			elseStart: Pos{Col: 3, Line: 4, Offset: 29},
 			Condition: String{
 				Text:   "false",
 				quoted: `"false"`,
-				start:  Pos{Col: 4, Line: 2},
-				end:    Pos{Col: 11, Line: 2},
+				start:  Pos{Col: 4, Line: 2, Offset: 4},
+				end:    Pos{Col: 11, Line: 2, Offset: 11},
 			},
 			Then: Block{
-				start: Pos{Col: 12, Line: 2},
-				end:   Pos{Col: 2, Line: 4},
+				start: Pos{Col: 12, Line: 2, Offset: 12},
+				end:   Pos{Col: 2, Line: 4, Offset: 28},
 				Statements: []Statement{
 					Instruction{
 						Text:   "then this",
 						quoted: `"then this"`,
-						start:  Pos{Col: 2, Line: 3},
-						end:    Pos{Col: 13, Line: 3},
+						start:  Pos{Col: 2, Line: 3, Offset: 15},
+						end:    Pos{Col: 13, Line: 3, Offset: 26},
 					},
 				},
 			},
 			Else: Block{
-				start: Pos{Col: 8, Line: 4},
-				end:   Pos{Col: 2, Line: 6},
+				start: Pos{Col: 8, Line: 4, Offset: 34},
+				end:   Pos{Col: 2, Line: 6, Offset: 50},
 				Statements: []Statement{
 					Instruction{
 						Text:   "else this",
 						quoted: `"else this"`,
-						start:  Pos{Col: 2, Line: 5},
-						end:    Pos{Col: 13, Line: 5},
+						start:  Pos{Col: 2, Line: 5, Offset: 37},
+						end:    Pos{Col: 13, Line: 5, Offset: 48},
 					},
 				},
 			},
 		},
 	}})
-	check.Eq(t, s.Statements[0].End(), Pos{Col: 2, Line: 6})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 2, Line: 6, Offset: 50})
 }
 
 func TestIfElseCanHaveTrueAndFalseTexts(t *testing.T) {
@@ -199,36 +206,37 @@ func TestIfElseCanHaveTrueAndFalseTexts(t *testing.T) {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		IfElse{
-			start: Pos{Col: 1, Line: 1},
+			start:     Pos{Col: 1, Line: 1, Offset: 0},
+			elseStart: Pos{Col: 16, Line: 1, Offset: 15},
 			Condition: String{
 				Text:   "",
 				quoted: `""`,
-				start:  Pos{Col: 4, Line: 1},
-				end:    Pos{Col: 6, Line: 1},
+				start:  Pos{Col: 4, Line: 1, Offset: 3},
+				end:    Pos{Col: 6, Line: 1, Offset: 5},
 			},
 			TrueText: String{
 				Text:   "yes",
 				quoted: `"yes"`,
-				start:  Pos{Col: 7, Line: 1},
-				end:    Pos{Col: 12, Line: 1},
+				start:  Pos{Col: 7, Line: 1, Offset: 6},
+				end:    Pos{Col: 12, Line: 1, Offset: 11},
 			},
 			Then: Block{
-				start: Pos{Col: 13, Line: 1},
-				end:   Pos{Col: 15, Line: 1},
+				start: Pos{Col: 13, Line: 1, Offset: 12},
+				end:   Pos{Col: 15, Line: 1, Offset: 14},
 			},
 			FalseText: String{
 				Text:   "no",
 				quoted: `"no"`,
-				start:  Pos{Col: 21, Line: 1},
-				end:    Pos{Col: 25, Line: 1},
+				start:  Pos{Col: 21, Line: 1, Offset: 20},
+				end:    Pos{Col: 25, Line: 1, Offset: 24},
 			},
 			Else: Block{
-				start: Pos{Col: 26, Line: 1},
-				end:   Pos{Col: 28, Line: 1},
+				start: Pos{Col: 26, Line: 1, Offset: 25},
+				end:   Pos{Col: 28, Line: 1, Offset: 27},
 			},
 		},
 	}})
-	check.Eq(t, s.Statements[0].End(), Pos{Col: 28, Line: 1})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 28, Line: 1, Offset: 27})
 }
 
 func TestSwitchCanBeEmpty(t *testing.T) {
@@ -238,13 +246,13 @@ switch "thing" {}
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		Switch{
-			start: Pos{Col: 1, Line: 2},
-			end:   Pos{Col: 18, Line: 2},
+			start: Pos{Col: 1, Line: 2, Offset: 1},
+			end:   Pos{Col: 18, Line: 2, Offset: 18},
 			Subject: String{
 				Text:   "thing",
 				quoted: `"thing"`,
-				start:  Pos{Col: 8, Line: 2},
-				end:    Pos{Col: 15, Line: 2},
+				start:  Pos{Col: 8, Line: 2, Offset: 8},
+				end:    Pos{Col: 15, Line: 2, Offset: 15},
 			},
 		},
 	}})
@@ -260,43 +268,45 @@ switch "x" {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		Switch{
-			start: Pos{Col: 1, Line: 2},
-			end:   Pos{Col: 2, Line: 5},
+			start: Pos{Col: 1, Line: 2, Offset: 1},
+			end:   Pos{Col: 2, Line: 5, Offset: 48},
 			Subject: String{
 				Text:   "x",
 				quoted: `"x"`,
-				start:  Pos{Col: 8, Line: 2},
-				end:    Pos{Col: 11, Line: 2},
+				start:  Pos{Col: 8, Line: 2, Offset: 8},
+				end:    Pos{Col: 11, Line: 2, Offset: 11},
 			},
 			Cases: []SwitchCase{
 				{
+					start: Pos{Col: 2, Line: 3, Offset: 15},
 					Condition: String{
 						Text:   "1",
 						quoted: `"1"`,
-						start:  Pos{Col: 7, Line: 3},
-						end:    Pos{Col: 10, Line: 3},
+						start:  Pos{Col: 7, Line: 3, Offset: 20},
+						end:    Pos{Col: 10, Line: 3, Offset: 23},
 					},
 					Block: Block{
-						start: Pos{Col: 11, Line: 3},
-						end:   Pos{Col: 13, Line: 3},
+						start: Pos{Col: 11, Line: 3, Offset: 24},
+						end:   Pos{Col: 13, Line: 3, Offset: 26},
 					},
 				},
 				{
+					start: Pos{Col: 2, Line: 4, Offset: 28},
 					Condition: String{
 						Text:   "2",
 						quoted: `"2"`,
-						start:  Pos{Col: 7, Line: 4},
-						end:    Pos{Col: 10, Line: 4},
+						start:  Pos{Col: 7, Line: 4, Offset: 33},
+						end:    Pos{Col: 10, Line: 4, Offset: 36},
 					},
 					Block: Block{
-						start: Pos{Col: 11, Line: 4},
-						end:   Pos{Col: 20, Line: 4},
+						start: Pos{Col: 11, Line: 4, Offset: 37},
+						end:   Pos{Col: 20, Line: 4, Offset: 46},
 						Statements: []Statement{
 							Instruction{
 								Text:   "two",
 								quoted: `"two"`,
-								start:  Pos{Col: 13, Line: 4},
-								end:    Pos{Col: 18, Line: 4},
+								start:  Pos{Col: 13, Line: 4, Offset: 39},
+								end:    Pos{Col: 18, Line: 4, Offset: 44},
 							},
 						},
 					},
@@ -316,32 +326,35 @@ switch "x" {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		Switch{
-			start: Pos{Col: 1, Line: 2},
-			end:   Pos{Col: 2, Line: 5},
+			start: Pos{Col: 1, Line: 2, Offset: 1},
+			end:   Pos{Col: 2, Line: 5, Offset: 45},
 			Subject: String{
 				Text:   "x",
 				quoted: `"x"`,
-				start:  Pos{Col: 8, Line: 2},
-				end:    Pos{Col: 11, Line: 2},
+				start:  Pos{Col: 8, Line: 2, Offset: 8},
+				end:    Pos{Col: 11, Line: 2, Offset: 11},
 			},
 			Cases: []SwitchCase{
 				{
+					start: Pos{Col: 2, Line: 3, Offset: 15},
 					Condition: String{
 						Text:   "1",
 						quoted: `"1"`,
-						start:  Pos{Col: 7, Line: 3},
-						end:    Pos{Col: 10, Line: 3},
+						start:  Pos{Col: 7, Line: 3, Offset: 20},
+						end:    Pos{Col: 10, Line: 3, Offset: 23},
 					},
 					Block: Block{
-						start: Pos{Col: 11, Line: 3},
-						end:   Pos{Col: 13, Line: 3},
+						start: Pos{Col: 11, Line: 3, Offset: 24},
+						end:   Pos{Col: 13, Line: 3, Offset: 26},
 					},
 				},
 				{
-					IsDefault: true,
+					start:        Pos{Col: 2, Line: 4, Offset: 28},
+					defaultStart: Pos{Col: 7, Line: 4, Offset: 33},
+					IsDefault:    true,
 					Block: Block{
-						start: Pos{Col: 15, Line: 4},
-						end:   Pos{Col: 17, Line: 4},
+						start: Pos{Col: 15, Line: 4, Offset: 41},
+						end:   Pos{Col: 17, Line: 4, Offset: 43},
 					},
 				},
 			},
@@ -354,26 +367,28 @@ func TestSwitchDefaultCaseCanHaveText(t *testing.T) {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		Switch{
-			start: Pos{Col: 1, Line: 1},
-			end:   Pos{Col: 37, Line: 1},
+			start: Pos{Col: 1, Line: 1, Offset: 0},
+			end:   Pos{Col: 37, Line: 1, Offset: 36},
 			Subject: String{
 				Text:   "",
 				quoted: `""`,
-				start:  Pos{Col: 8, Line: 1},
-				end:    Pos{Col: 10, Line: 1},
+				start:  Pos{Col: 8, Line: 1, Offset: 7},
+				end:    Pos{Col: 10, Line: 1, Offset: 9},
 			},
 			Cases: []SwitchCase{
 				{
-					IsDefault: true,
+					start:        Pos{Col: 13, Line: 1, Offset: 12},
+					defaultStart: Pos{Col: 18, Line: 1, Offset: 17},
+					IsDefault:    true,
 					Condition: String{
 						Text:   "else",
 						quoted: `"else"`,
-						start:  Pos{Col: 26, Line: 1},
-						end:    Pos{Col: 32, Line: 1},
+						start:  Pos{Col: 26, Line: 1, Offset: 25},
+						end:    Pos{Col: 32, Line: 1, Offset: 31},
 					},
 					Block: Block{
-						start: Pos{Col: 33, Line: 1},
-						end:   Pos{Col: 35, Line: 1},
+						start: Pos{Col: 33, Line: 1, Offset: 32},
+						end:   Pos{Col: 35, Line: 1, Offset: 34},
 					},
 				},
 			},
@@ -386,22 +401,22 @@ func TestInfiniteLoopHasNoCondition(t *testing.T) {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		InfiniteLoop{
-			start: Pos{Col: 1, Line: 1},
+			start: Pos{Col: 1, Line: 1, Offset: 0},
 			Block: Block{
-				start: Pos{Col: 7, Line: 1},
-				end:   Pos{Col: 15, Line: 1},
+				start: Pos{Col: 7, Line: 1, Offset: 6},
+				end:   Pos{Col: 15, Line: 1, Offset: 14},
 				Statements: []Statement{
 					Instruction{
 						Text:   "do",
 						quoted: `"do"`,
-						start:  Pos{Col: 9, Line: 1},
-						end:    Pos{Col: 13, Line: 1},
+						start:  Pos{Col: 9, Line: 1, Offset: 8},
+						end:    Pos{Col: 13, Line: 1, Offset: 12},
 					},
 				},
 			},
 		},
 	}})
-	check.Eq(t, s.Statements[0].End(), Pos{Col: 15, Line: 1})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 15, Line: 1, Offset: 14})
 }
 
 func TestWhileLoopHasConditionNextToTheWhileKeyword(t *testing.T) {
@@ -409,28 +424,28 @@ func TestWhileLoopHasConditionNextToTheWhileKeyword(t *testing.T) {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		While{
-			start: Pos{Col: 1, Line: 1},
+			start: Pos{Col: 1, Line: 1, Offset: 0},
 			Condition: String{
 				Text:   "condition",
 				quoted: `"condition"`,
-				start:  Pos{Col: 7, Line: 1},
-				end:    Pos{Col: 18, Line: 1},
+				start:  Pos{Col: 7, Line: 1, Offset: 6},
+				end:    Pos{Col: 18, Line: 1, Offset: 17},
 			},
 			Block: Block{
-				start: Pos{Col: 19, Line: 1},
-				end:   Pos{Col: 27, Line: 1},
+				start: Pos{Col: 19, Line: 1, Offset: 18},
+				end:   Pos{Col: 27, Line: 1, Offset: 26},
 				Statements: []Statement{
 					Instruction{
 						Text:   "do",
 						quoted: `"do"`,
-						start:  Pos{Col: 21, Line: 1},
-						end:    Pos{Col: 25, Line: 1},
+						start:  Pos{Col: 21, Line: 1, Offset: 20},
+						end:    Pos{Col: 25, Line: 1, Offset: 24},
 					},
 				},
 			},
 		},
 	}})
-	check.Eq(t, s.Statements[0].End(), Pos{Col: 27, Line: 1})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 27, Line: 1, Offset: 26})
 }
 
 func TestDoWhileLoopHasConditionInFooter(t *testing.T) {
@@ -438,28 +453,29 @@ func TestDoWhileLoopHasConditionInFooter(t *testing.T) {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		DoWhile{
-			start: Pos{Col: 1, Line: 1},
+			start:      Pos{Col: 1, Line: 1, Offset: 0},
+			whileStart: Pos{Col: 13, Line: 1, Offset: 12},
 			Block: Block{
-				start: Pos{Col: 4, Line: 1},
-				end:   Pos{Col: 12, Line: 1},
+				start: Pos{Col: 4, Line: 1, Offset: 3},
+				end:   Pos{Col: 12, Line: 1, Offset: 11},
 				Statements: []Statement{
 					Instruction{
 						Text:   "do",
 						quoted: `"do"`,
-						start:  Pos{Col: 6, Line: 1},
-						end:    Pos{Col: 10, Line: 1},
+						start:  Pos{Col: 6, Line: 1, Offset: 5},
+						end:    Pos{Col: 10, Line: 1, Offset: 9},
 					},
 				},
 			},
 			Condition: String{
 				Text:   "condition",
 				quoted: `"condition"`,
-				start:  Pos{Col: 19, Line: 1},
-				end:    Pos{Col: 30, Line: 1},
+				start:  Pos{Col: 19, Line: 1, Offset: 18},
+				end:    Pos{Col: 30, Line: 1, Offset: 29},
 			},
 		},
 	}})
-	check.Eq(t, s.Statements[0].End(), Pos{Col: 30, Line: 1})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 30, Line: 1, Offset: 29})
 }
 
 func TestLoopsCanHaveBreaks(t *testing.T) {
@@ -467,22 +483,305 @@ func TestLoopsCanHaveBreaks(t *testing.T) {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		InfiniteLoop{
-			start: Pos{Col: 1, Line: 1},
+			start: Pos{Col: 1, Line: 1, Offset: 0},
 			Block: Block{
-				start: Pos{Col: 7, Line: 1},
-				end:   Pos{Col: 30, Line: 1},
+				start: Pos{Col: 7, Line: 1, Offset: 6},
+				end:   Pos{Col: 30, Line: 1, Offset: 29},
 				Statements: []Statement{
 					Break{
-						Text:   "destination",
-						quoted: `"destination"`,
-						start:  Pos{Col: 9, Line: 1},
-						end:    Pos{Col: 28, Line: 1},
+						Text:      "destination",
+						quoted:    `"destination"`,
+						start:     Pos{Col: 9, Line: 1, Offset: 8},
+						end:       Pos{Col: 28, Line: 1, Offset: 27},
+						textStart: Pos{Col: 15, Line: 1, Offset: 14},
+					},
+				},
+			},
+		},
+	}})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 30, Line: 1, Offset: 29})
+}
+
+func TestJumpStatementsHaveKeywordAndText(t *testing.T) {
+	s, err := ParseString(`return "x" continue "" exit "error"`)
+	check.Eq(t, err, nil)
+	check.Eq(t, s, &Structogram{Statements: []Statement{
+		Return{
+			Text:      "x",
+			quoted:    `"x"`,
+			start:     Pos{Col: 1, Line: 1, Offset: 0},
+			end:       Pos{Col: 11, Line: 1, Offset: 10},
+			textStart: Pos{Col: 8, Line: 1, Offset: 7},
+		},
+		Continue{
+			Text:      "",
+			quoted:    `""`,
+			start:     Pos{Col: 12, Line: 1, Offset: 11},
+			end:       Pos{Col: 23, Line: 1, Offset: 22},
+			textStart: Pos{Col: 21, Line: 1, Offset: 20},
+		},
+		Exit{
+			Text:      "error",
+			quoted:    `"error"`,
+			start:     Pos{Col: 24, Line: 1, Offset: 23},
+			end:       Pos{Col: 36, Line: 1, Offset: 35},
+			textStart: Pos{Col: 29, Line: 1, Offset: 28},
+		},
+	}})
+	check.Eq(t, s.Statements[2].(Exit).Keyword().End(), Pos{Col: 28, Line: 1, Offset: 27})
+}
+
+func TestElseIfMakesAnIfChain(t *testing.T) {
+	s, err := ParseString(`if "a" {} else if "b" "T" {} else {}`)
+	check.Eq(t, err, nil)
+	check.Eq(t, s, &Structogram{Statements: []Statement{
+		IfChain{
+			start:     Pos{Col: 1, Line: 1, Offset: 0},
+			elseStart: Pos{Col: 30, Line: 1, Offset: 29},
+			Condition: String{
+				Text:   "a",
+				quoted: `"a"`,
+				start:  Pos{Col: 4, Line: 1, Offset: 3},
+				end:    Pos{Col: 7, Line: 1, Offset: 6},
+			},
+			Then: Block{
+				start: Pos{Col: 8, Line: 1, Offset: 7},
+				end:   Pos{Col: 10, Line: 1, Offset: 9},
+			},
+			ElseIfs: []ElseIf{
+				{
+					start:   Pos{Col: 11, Line: 1, Offset: 10},
+					ifStart: Pos{Col: 16, Line: 1, Offset: 15},
+					Condition: String{
+						Text:   "b",
+						quoted: `"b"`,
+						start:  Pos{Col: 19, Line: 1, Offset: 18},
+						end:    Pos{Col: 22, Line: 1, Offset: 21},
+					},
+					TrueText: String{
+						Text:   "T",
+						quoted: `"T"`,
+						start:  Pos{Col: 23, Line: 1, Offset: 22},
+						end:    Pos{Col: 26, Line: 1, Offset: 25},
+					},
+					Block: Block{
+						start: Pos{Col: 27, Line: 1, Offset: 26},
+						end:   Pos{Col: 29, Line: 1, Offset: 28},
+					},
+				},
+			},
+			HasElse: true,
+			Else: Block{
+				start: Pos{Col: 35, Line: 1, Offset: 34},
+				end:   Pos{Col: 37, Line: 1, Offset: 36},
+			},
+		},
+	}})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 37, Line: 1, Offset: 36})
+
+	s, err = ParseString(`if "a" {} else if "b" {} else if "c" {}`)
+	check.Eq(t, err, nil)
+	chain := s.Statements[0].(IfChain)
+	check.Eq(t, len(chain.ElseIfs), 2)
+	check.Eq(t, chain.ElseIfs[1].Condition.Text, "c")
+	check.Eq(t, chain.HasElse, false)
+	check.Eq(t, chain.End(), Pos{Col: 40, Line: 1, Offset: 39})
+}
+
+func TestRepeatUntilIsItsOwnLoop(t *testing.T) {
+	s, err := ParseString(`repeat { "a" } until "b"`)
+	check.Eq(t, err, nil)
+	check.Eq(t, s, &Structogram{Statements: []Statement{
+		RepeatUntil{
+			start:      Pos{Col: 1, Line: 1, Offset: 0},
+			untilStart: Pos{Col: 16, Line: 1, Offset: 15},
+			Block: Block{
+				start: Pos{Col: 8, Line: 1, Offset: 7},
+				end:   Pos{Col: 15, Line: 1, Offset: 14},
+				Statements: []Statement{
+					Instruction{
+						Text:   "a",
+						quoted: `"a"`,
+						start:  Pos{Col: 10, Line: 1, Offset: 9},
+						end:    Pos{Col: 13, Line: 1, Offset: 12},
+					},
+				},
+			},
+			Condition: String{
+				Text:   "b",
+				quoted: `"b"`,
+				start:  Pos{Col: 22, Line: 1, Offset: 21},
+				end:    Pos{Col: 25, Line: 1, Offset: 24},
+			},
+		},
+	}})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 25, Line: 1, Offset: 24})
+}
+
+func TestRepeatNeedsUntil(t *testing.T) {
+	_, err := ParseString(`repeat {} while "x"`)
+	check.Eq(t, err.Error(), "parse error: 1:11: keyword 'until' at the end of repeat-until loop expected but found identifier \"while\"")
+}
+
+func TestTryHasCatchesAndOptionalFinally(t *testing.T) {
+	s, err := ParseString(`try { "a" } catch "E" {} finally {}`)
+	check.Eq(t, err, nil)
+	check.Eq(t, s, &Structogram{Statements: []Statement{
+		Try{
+			start:        Pos{Col: 1, Line: 1, Offset: 0},
+			finallyStart: Pos{Col: 26, Line: 1, Offset: 25},
+			Block: Block{
+				start: Pos{Col: 5, Line: 1, Offset: 4},
+				end:   Pos{Col: 12, Line: 1, Offset: 11},
+				Statements: []Statement{
+					Instruction{
+						Text:   "a",
+						quoted: `"a"`,
+						start:  Pos{Col: 7, Line: 1, Offset: 6},
+						end:    Pos{Col: 10, Line: 1, Offset: 9},
+					},
+				},
+			},
+			Catches: []Catch{
+				{
+					start: Pos{Col: 13, Line: 1, Offset: 12},
+					Exception: String{
+						Text:   "E",
+						quoted: `"E"`,
+						start:  Pos{Col: 19, Line: 1, Offset: 18},
+						end:    Pos{Col: 22, Line: 1, Offset: 21},
+					},
+					Block: Block{
+						start: Pos{Col: 23, Line: 1, Offset: 22},
+						end:   Pos{Col: 25, Line: 1, Offset: 24},
+					},
+				},
+			},
+			HasFinally: true,
+			Finally: Block{
+				start: Pos{Col: 34, Line: 1, Offset: 33},
+				end:   Pos{Col: 36, Line: 1, Offset: 35},
+			},
+		},
+	}})
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 36, Line: 1, Offset: 35})
+
+	s, err = ParseString(`try {} catch {} catch "F" {}`)
+	check.Eq(t, err, nil)
+	try := s.Statements[0].(Try)
+	check.Eq(t, len(try.Catches), 2)
+	check.Eq(t, try.Catches[0].Exception.given(), false)
+	check.Eq(t, try.Catches[1].Exception.Text, "F")
+	check.Eq(t, try.HasFinally, false)
+	check.Eq(t, try.End(), Pos{Col: 29, Line: 1, Offset: 28})
+}
+
+func TestTryNeedsACatch(t *testing.T) {
+	_, err := ParseString(`try {} finally {}`)
+	check.Eq(t, err.Error(), "parse error: 1:8: keyword 'catch' after try block expected but found identifier \"finally\"")
+}
+
+func TestForLoopHasVariableRangeAndOptionalStep(t *testing.T) {
+	s, err := ParseString(`for "i" "1" "10" "step 2" {} for "j" "9" "0" {}`)
+	check.Eq(t, err, nil)
+	check.Eq(t, s, &Structogram{Statements: []Statement{
+		For{
+			start: Pos{Col: 1, Line: 1, Offset: 0},
+			Variable: String{
+				Text:   "i",
+				quoted: `"i"`,
+				start:  Pos{Col: 5, Line: 1, Offset: 4},
+				end:    Pos{Col: 8, Line: 1, Offset: 7},
+			},
+			From: String{
+				Text:   "1",
+				quoted: `"1"`,
+				start:  Pos{Col: 9, Line: 1, Offset: 8},
+				end:    Pos{Col: 12, Line: 1, Offset: 11},
+			},
+			To: String{
+				Text:   "10",
+				quoted: `"10"`,
+				start:  Pos{Col: 13, Line: 1, Offset: 12},
+				end:    Pos{Col: 17, Line: 1, Offset: 16},
+			},
+			Step: String{
+				Text:   "step 2",
+				quoted: `"step 2"`,
+				start:  Pos{Col: 18, Line: 1, Offset: 17},
+				end:    Pos{Col: 26, Line: 1, Offset: 25},
+			},
+			Block: Block{
+				start: Pos{Col: 27, Line: 1, Offset: 26},
+				end:   Pos{Col: 29, Line: 1, Offset: 28},
+			},
+		},
+		For{
+			start: Pos{Col: 30, Line: 1, Offset: 29},
+			Variable: String{
+				Text:   "j",
+				quoted: `"j"`,
+				start:  Pos{Col: 34, Line: 1, Offset: 33},
+				end:    Pos{Col: 37, Line: 1, Offset: 36},
+			},
+			From: String{
+				Text:   "9",
+				quoted: `"9"`,
+				start:  Pos{Col: 38, Line: 1, Offset: 37},
+				end:    Pos{Col: 41, Line: 1, Offset: 40},
+			},
+			To: String{
+				Text:   "0",
+				quoted: `"0"`,
+				start:  Pos{Col: 42, Line: 1, Offset: 41},
+				end:    Pos{Col: 45, Line: 1, Offset: 44},
+			},
+			Block: Block{
+				start: Pos{Col: 46, Line: 1, Offset: 45},
+				end:   Pos{Col: 48, Line: 1, Offset: 47},
+			},
+		},
+	}})
+	check.Eq(t, s.Statements[1].End(), Pos{Col: 48, Line: 1, Offset: 47})
+}
+
+func TestForeachLoopHasVariableAndCollection(t *testing.T) {
+	s, err := ParseString(`foreach "x" "in xs" { "a" }`)
+	check.Eq(t, err, nil)
+	check.Eq(t, s, &Structogram{Statements: []Statement{
+		Foreach{
+			start: Pos{Col: 1, Line: 1, Offset: 0},
+			Variable: String{
+				Text:   "x",
+				quoted: `"x"`,
+				start:  Pos{Col: 9, Line: 1, Offset: 8},
+				end:    Pos{Col: 12, Line: 1, Offset: 11},
+			},
+			Collection: String{
+				Text:   "in xs",
+				quoted: `"in xs"`,
+				start:  Pos{Col: 13, Line: 1, Offset: 12},
+				end:    Pos{Col: 20, Line: 1, Offset: 19},
+			},
+			Block: Block{
+				start: Pos{Col: 21, Line: 1, Offset: 20},
+				end:   Pos{Col: 28, Line: 1, Offset: 27},
+				Statements: []Statement{
+					Instruction{
+						Text:   "a",
+						quoted: `"a"`,
+						start:  Pos{Col: 23, Line: 1, Offset: 22},
+						end:    Pos{Col: 26, Line: 1, Offset: 25},
 					},
 				},
 			},
 		},
 	}})
-	check.Eq(t, s.Statements[0].End(), Pos{Col: 30, Line: 1})
+}
+
+func TestForLoopNeedsVariableFromAndTo(t *testing.T) {
+	_, err := ParseString(`for "i" "1" {}`)
+	check.Eq(t, err.Error(), "parse error: 1:13: string expected but found token '{'")
 }
 
 func TestCallBlockHasOneStringInstruction(t *testing.T) {
@@ -490,14 +789,58 @@ func TestCallBlockHasOneStringInstruction(t *testing.T) {
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		Call{
-			Text:   "instruction",
-			quoted: `"instruction"`,
-			start:  Pos{Col: 1, Line: 1},
-			end:    Pos{Col: 19, Line: 1},
+			Text:      "instruction",
+			quoted:    `"instruction"`,
+			start:     Pos{Col: 1, Line: 1, Offset: 0},
+			end:       Pos{Col: 19, Line: 1, Offset: 18},
+			textStart: Pos{Col: 6, Line: 1, Offset: 5},
 		},
 	}})
 }
 
+func TestPositionsHaveByteOffsets(t *testing.T) {
+	s, err := ParseString("\"î\" \"b\"")
+	check.Eq(t, err, nil)
+	check.Eq(t, s.Statements[0].End(), Pos{Col: 4, Line: 1, Offset: 4})
+	check.Eq(t, s.Statements[1].Start(), Pos{Col: 5, Line: 1, Offset: 5})
+}
+
+func TestKeywordsHaveRanges(t *testing.T) {
+	s, err := ParseString(`title "t"
+if "a" {} else {}
+switch "b" { case default {} }
+do {} while "c"
+call "d"`)
+	check.Eq(t, err, nil)
+	keyword := func(k Keyword, text string, col, line, offset int) {
+		t.Helper()
+		check.Eq(t, k.Text, text)
+		check.Eq(t, k.Start(), Pos{Col: col, Line: line, Offset: offset})
+		check.Eq(t, k.End(), Pos{Col: col + len(text), Line: line, Offset: offset + len(text)})
+	}
+	keyword(s.TitleKeyword(), "title", 1, 1, 0)
+	ifElse := s.Statements[0].(IfElse)
+	keyword(ifElse.Keyword(), "if", 1, 2, 10)
+	keyword(ifElse.ElseKeyword(), "else", 11, 2, 20)
+	switchCase := s.Statements[1].(Switch).Cases[0]
+	keyword(s.Statements[1].(Switch).Keyword(), "switch", 1, 3, 28)
+	keyword(switchCase.Keyword(), "case", 14, 3, 41)
+	keyword(switchCase.DefaultKeyword(), "default", 19, 3, 46)
+	doWhile := s.Statements[2].(DoWhile)
+	keyword(doWhile.Keyword(), "do", 1, 4, 59)
+	keyword(doWhile.WhileKeyword(), "while", 7, 4, 65)
+	call := s.Statements[3].(Call)
+	keyword(call.Keyword(), "call", 1, 5, 75)
+	check.Eq(t, call.TextStart(), Pos{Col: 6, Line: 5, Offset: 80})
+	check.Eq(t, s.Start(), Pos{Col: 1, Line: 1, Offset: 0})
+}
+
+func TestKeywordsOfBuiltStatementsHaveNoPositions(t *testing.T) {
+	s := NewBuilder("t").IfElse("a", nil, nil).Structogram()
+	check.Eq(t, s.TitleKeyword(), Keyword{Text: "title"})
+	check.Eq(t, s.Statements[0].(IfElse).ElseKeyword(), Keyword{Text: "else"})
+}
+
 func TestParallelExecutionHasSubBlocks(t *testing.T) {
 	s, err := ParseString(`
 parallel {
@@ -515,47 +858,299 @@ parallel {}
 	check.Eq(t, err, nil)
 	check.Eq(t, s, &Structogram{Statements: []Statement{
 		Parallel{
-			start: Pos{Col: 1, Line: 2},
-			end:   Pos{Col: 2, Line: 10},
+			start: Pos{Col: 1, Line: 2, Offset: 1},
+			end:   Pos{Col: 2, Line: 10, Offset: 53},
 			Blocks: []Block{
 				Block{
-					start: Pos{Col: 2, Line: 3},
-					end:   Pos{Col: 3, Line: 5},
+					start: Pos{Col: 2, Line: 3, Offset: 13},
+					end:   Pos{Col: 3, Line: 5, Offset: 29},
 					Statements: []Statement{
 						Instruction{
 							Text:   "block 1",
 							quoted: `"block 1"`,
-							start:  Pos{Col: 3, Line: 4},
-							end:    Pos{Col: 12, Line: 4},
+							start:  Pos{Col: 3, Line: 4, Offset: 17},
+							end:    Pos{Col: 12, Line: 4, Offset: 26},
 						},
 					},
 				},
 				Block{
-					start: Pos{Col: 2, Line: 6},
-					end:   Pos{Col: 4, Line: 6},
+					start: Pos{Col: 2, Line: 6, Offset: 31},
+					end:   Pos{Col: 4, Line: 6, Offset: 33},
 				},
 				Block{
-					start: Pos{Col: 2, Line: 7},
-					end:   Pos{Col: 3, Line: 9},
+					start: Pos{Col: 2, Line: 7, Offset: 35},
+					end:   Pos{Col: 3, Line: 9, Offset: 51},
 					Statements: []Statement{
 						Instruction{
 							Text:   "block 3",
 							quoted: `"block 3"`,
-							start:  Pos{Col: 3, Line: 8},
-							end:    Pos{Col: 12, Line: 8},
+							start:  Pos{Col: 3, Line: 8, Offset: 39},
+							end:    Pos{Col: 12, Line: 8, Offset: 48},
 						},
 					},
 				},
 			},
 		},
 		Parallel{
-			start: Pos{Col: 1, Line: 12},
-			end:   Pos{Col: 12, Line: 12},
+			start: Pos{Col: 1, Line: 12, Offset: 55},
+			end:   Pos{Col: 12, Line: 12, Offset: 66},
 		},
 	}})
 }
 
 func TestIncompleteSwitchGivesParseError(t *testing.T) {
 	_, err := ParseString(`switch "" {`)
-	check.Eq(t, err.Error(), "parse error: token '}' expected")
+	check.Eq(t, err.Error(), "parse error: 1:12: token '}' expected but found end of input")
+}
+
+func TestParseErrorsHaveTheRangeOfTheOffendingToken(t *testing.T) {
+	_, err := ParseString("if \"a\" {\n\tif {}\n}")
+	check.Eq(t, err, &Error{
+		Start:    Pos{Col: 5, Line: 2, Offset: 13},
+		End:      Pos{Col: 6, Line: 2, Offset: 14},
+		Expected: "string",
+		Found:    "token '{'",
+	})
+	check.Eq(t, err.Error(), "parse error: 2:5: string expected but found token '{'")
+}
+
+func TestParseErrorKeepsTheFirstError(t *testing.T) {
+	_, err := ParseString(`call whlie`)
+	check.Eq(t, err, &Error{
+		Start:    Pos{Col: 6, Line: 1, Offset: 5},
+		End:      Pos{Col: 11, Line: 1, Offset: 10},
+		Expected: "string",
+		Found:    `identifier "whlie"`,
+	})
+}
+
+func TestDoWhileWithoutWhileGivesParseError(t *testing.T) {
+	_, err := ParseString(`do {} "x"`)
+	check.Eq(t, err, &Error{
+		Start:    Pos{Col: 7, Line: 1, Offset: 6},
+		End:      Pos{Col: 10, Line: 1, Offset: 9},
+		Expected: "keyword 'while' at the end of do-while loop",
+		Found:    `string "x"`,
+	})
+}
+
+func TestTokenizerErrorsAreParseErrors(t *testing.T) {
+	_, err := ParseString(`"a" #`)
+	check.Eq(t, err, &Error{
+		Start: Pos{Col: 5, Line: 1, Offset: 4},
+		End:   Pos{Col: 6, Line: 1, Offset: 5},
+		Found: "character '#'",
+	})
+	check.Eq(t, err.Error(), "parse error: 1:5: unexpected character '#'")
+
+	_, err = ParseString("\"a\n")
+	check.Eq(t, err, &Error{
+		Start:    Pos{Col: 1, Line: 2, Offset: 3},
+		End:      Pos{Col: 1, Line: 2, Offset: 3},
+		Expected: `closing '"' of string`,
+		Found:    "end of input",
+	})
+
+	_, err = ParseString(`"\t"`)
+	check.Eq(t, err.Error(), `parse error: 1:3: escape sequence '\n', '\\' or '\"' expected but found escape sequence '\t'`)
+}
+
+func TestTrailingInputGivesParseError(t *testing.T) {
+	_, err := ParseString(`"a" foo "b"`)
+	check.Eq(t, err.Error(), `parse error: 1:5: statement expected but found identifier "foo"`)
+
+	_, err = ParseString("\"a\"\n}")
+	check.Eq(t, err, &Error{
+		Start:    Pos{Col: 1, Line: 2, Offset: 4},
+		End:      Pos{Col: 2, Line: 2, Offset: 5},
+		Expected: "statement",
+		Found:    "token '}'",
+	})
+}
+
+func TestMisspelledKeywordsAreSuggested(t *testing.T) {
+	_, err := ParseString(`whlie "x" {}`)
+	check.Eq(t, err, &Error{
+		Start:      Pos{Col: 1, Line: 1, Offset: 0},
+		End:        Pos{Col: 6, Line: 1, Offset: 5},
+		Expected:   "statement",
+		Found:      `identifier "whlie"`,
+		Suggestion: "while",
+	})
+	check.Eq(t, err.Error(), `parse error: 1:1: statement expected but found identifier "whlie", did you mean "while"?`)
+
+	_, err = ParseString(`if "" { swtich "" {} }`)
+	check.Eq(t, err.Error(), `parse error: 1:9: token '}' expected but found identifier "swtich", did you mean "switch"?`)
+
+	_, err = ParseString(`switch "" { csae "a" {} }`)
+	check.Eq(t, err.Error(), `parse error: 1:13: token '}' expected but found identifier "csae", did you mean "case"?`)
+}
+
+func TestSuggestKeyword(t *testing.T) {
+	check.Eq(t, suggestKeyword("whlie", statementKeywords), "while")
+	check.Eq(t, suggestKeyword("Call", statementKeywords), "call")
+	check.Eq(t, suggestKeyword("paralell", statementKeywords), "parallel")
+	check.Eq(t, suggestKeyword("fi", statementKeywords), "if")
+	check.Eq(t, suggestKeyword("fro", statementKeywords), "for")
+	check.Eq(t, suggestKeyword("foreahc", statementKeywords), "foreach")
+	check.Eq(t, suggestKeyword("foo", statementKeywords), "")
+	check.Eq(t, suggestKeyword("go", statementKeywords), "")
+	check.Eq(t, suggestKeyword("to", statementKeywords), "")
+	check.Eq(t, suggestKeyword("it", statementKeywords), "")
+	check.Eq(t, suggestKeyword("IF", statementKeywords), "if")
+	check.Eq(t, suggestKeyword("while", statementKeywords), "")
+}
+
+func TestParsePartialReportsAllErrors(t *testing.T) {
+	s, errs := ParsePartial(`"a"
+whlie "x" { "b" }
+if {
+	"c"
+	foo
+}
+"d"`)
+	check.Eq(t, errs.Error(), `parse error: 2:1: statement expected but found identifier "whlie", did you mean "while"? (and 2 more errors)`)
+	check.Eq(t, len(errs), 3)
+	check.Eq(t, errs[1].Error(), `parse error: 3:4: string expected but found token '{'`)
+	check.Eq(t, errs[2].Error(), `parse error: 5:2: token '}' expected but found identifier "foo"`)
+
+	// Invalid code is skipped up to the next string, which is a valid
+	// instruction. The block after it is invalid on its own.
+	check.Eq(t, len(s.Statements), 6)
+	check.Eq(t, s.Statements[0].(Instruction).Text, "a")
+	check.Eq(t, s.Statements[1], BadStatement{
+		Text:  `whlie`,
+		start: Pos{Col: 1, Line: 2, Offset: 4},
+		end:   Pos{Col: 6, Line: 2, Offset: 9},
+	})
+	check.Eq(t, s.Statements[2].(Instruction).Text, "x")
+	check.Eq(t, s.Statements[3], BadStatement{
+		Text:  `{ "b" }`,
+		start: Pos{Col: 11, Line: 2, Offset: 14},
+		end:   Pos{Col: 18, Line: 2, Offset: 21},
+	})
+	then := s.Statements[4].(If).Then.Statements
+	check.Eq(t, len(then), 2)
+	check.Eq(t, then[0].(Instruction).Text, "c")
+	check.Eq(t, then[1].(BadStatement).Text, "foo")
+	check.Eq(t, s.Statements[5].(Instruction).Text, "d")
+}
+
+func TestParsePartialReportsOneErrorPerLine(t *testing.T) {
+	_, errs := ParsePartial(`if "a" "b" "c" {}`)
+	check.Eq(t, errs.Error(), `parse error: 1:12: token '{' expected but found string "c"`)
+}
+
+func TestParsePartialRecoversInSwitch(t *testing.T) {
+	s, errs := ParsePartial(`switch "" {
+	csae "a" {}
+	case "b" {}
+}
+"c"`)
+	check.Eq(t, errs.Error(), `parse error: 2:2: token '}' expected but found identifier "csae", did you mean "case"?`)
+	check.Eq(t, len(s.Statements), 2)
+	cases := s.Statements[0].(Switch).Cases
+	check.Eq(t, len(cases), 1)
+	check.Eq(t, cases[0].Condition.Text, "b")
+}
+
+func TestParsePartialSkipsIllegalCharacters(t *testing.T) {
+	s, errs := ParsePartial(`"a" # "b" $`)
+	check.Eq(t, len(errs), 2)
+	check.Eq(t, errs[0].Error(), "parse error: 1:5: unexpected character '#'")
+	check.Eq(t, errs[1].Error(), "parse error: 1:11: unexpected character '$'")
+	check.Eq(t, len(s.Statements), 2)
+}
+
+func TestNULIsAnIllegalCharacter(t *testing.T) {
+	_, err := ParseString("\"a\" \x00 \"b\"")
+	check.Eq(t, err.Error(), `parse error: 1:5: unexpected character '\x00'`)
+
+	s, errs := ParsePartial("\"a\" \x00 \"b\"")
+	check.Eq(t, len(errs), 1)
+	check.Eq(t, len(s.Statements), 2)
+}
+
+func TestParsePartialKeepsInstructionsAfterInvalidCode(t *testing.T) {
+	s, errs := ParsePartial(`"a" foo "b"`)
+	check.Eq(t, len(errs), 1)
+	check.Eq(t, len(s.Statements), 3)
+	check.Eq(t, s.Statements[0].(Instruction).Text, "a")
+	check.Eq(t, s.Statements[1].(BadStatement).Text, "foo")
+	check.Eq(t, s.Statements[2].(Instruction).Text, "b")
+
+	s, errs = ParsePartial(`"a" } "b" "c"`)
+	check.Eq(t, len(errs), 1)
+	check.Eq(t, len(s.Statements), 4)
+	check.Eq(t, s.Statements[1].(BadStatement).Text, "}")
+	check.Eq(t, s.Statements[2].(Instruction).Text, "b")
+	check.Eq(t, s.Statements[3].(Instruction).Text, "c")
+}
+
+func TestMissingStringsHaveNoTextOrLength(t *testing.T) {
+	s, errs := ParsePartial("if {}\ncall }")
+	check.Eq(t, len(errs), 2)
+	check.Eq(t, s.Statements[0].(If).Condition, String{
+		start: Pos{Col: 3, Line: 1, Offset: 2},
+		end:   Pos{Col: 3, Line: 1, Offset: 2},
+	})
+	call := s.Statements[1].(Call)
+	check.Eq(t, call.quoted, "")
+	check.Eq(t, call.TextStart(), Pos{Col: 5, Line: 2, Offset: 10})
+	check.Eq(t, call.End(), Pos{Col: 5, Line: 2, Offset: 10})
+}
+
+func TestParsePartialWithoutErrors(t *testing.T) {
+	s, errs := ParsePartial(`"a"`)
+	check.Eq(t, len(errs), 0)
+	check.Eq(t, len(s.Statements), 1)
+}
+
+func TestCommentsAreTokens(t *testing.T) {
+	tokens, errs := tokenize("\"a\" // line\n/* block\n*/")
+	check.Eq(t, len(errs), 0)
+	check.Eq(t, tokens, []token{
+		{typ: tokenString, text: `"a"`, col: 1, line: 1},
+		{typ: tokenSpace, text: " ", col: 4, line: 1, offset: 3},
+		{typ: tokenComment, text: "// line", col: 5, line: 1, offset: 4},
+		{typ: tokenSpace, text: "\n", col: 12, line: 1, offset: 11},
+		{typ: tokenComment, text: "/* block\n*/", col: 1, line: 2, offset: 12},
+		{typ: tokenEOF, text: "", col: 3, line: 3, offset: 23},
+	})
+}
+
+func TestInvalidCommentsGiveParseErrors(t *testing.T) {
+	_, err := ParseString(`"a" /* open`)
+	check.Eq(t, err.Error(), `parse error: 1:12: closing '*/' of comment expected but found end of input`)
+
+	_, err = ParseString(`"a" / "b"`)
+	check.Eq(t, err.Error(), `parse error: 1:5: unexpected character '/'`)
+}
+
+func TestCommentsAreAttachedToStatements(t *testing.T) {
+	s, err := ParseString(`// leading
+"a" /* trailing */ // comments
+// next
+"b"
+// end`)
+	check.Eq(t, err, nil)
+	check.Eq(t, s.Statements[0].(Instruction).Comments, Comments{
+		Leading: []Comment{
+			{Text: "// leading", start: Pos{Col: 1, Line: 1, Offset: 0}, end: Pos{Col: 11, Line: 1, Offset: 10}},
+		},
+		Trailing: []Comment{
+			{Text: "/* trailing */", start: Pos{Col: 5, Line: 2, Offset: 15}, end: Pos{Col: 19, Line: 2, Offset: 29}},
+			{Text: "// comments", start: Pos{Col: 20, Line: 2, Offset: 30}, end: Pos{Col: 31, Line: 2, Offset: 41}},
+		},
+	})
+	check.Eq(t, s.Statements[1].(Instruction).Leading[0].Text, "// next")
+	check.Eq(t, s.EndComments[0].Text, "// end")
+}
+
+func TestCommentsAtTheEndOfBlocksBelongToTheBlock(t *testing.T) {
+	s, err := ParseString("while {\n\t\"a\"\n\t// end\n} // after")
+	check.Eq(t, err, nil)
+	loop := s.Statements[0].(InfiniteLoop)
+	check.Eq(t, loop.Block.EndComments[0].Text, "// end")
+	check.Eq(t, loop.Trailing[0].Text, "// after")
 }
@@ -0,0 +1,92 @@
+package parser
+
+import "strings"
+
+// statementKeywords are the keywords that can start a statement.
+var statementKeywords = []string{
+	"if", "switch", "while", "do", "repeat", "for", "foreach", "try", "break", "return", "continue", "exit", "call",
+	"parallel",
+}
+
+// suggestKeyword returns the keyword that the given identifier is most likely
+// a misspelling of, or the empty string if none is similar enough.
+func suggestKeyword(id string, keywords []string) string {
+	best, bestDist := "", 0
+	for _, keyword := range keywords {
+		if id == keyword {
+			// The keyword is spelled correctly, it is just in the wrong place.
+			return ""
+		}
+	}
+	// Keywords are lower case, different case does not count as a typo.
+	id = strings.ToLower(id)
+	for _, keyword := range keywords {
+		// Allow one typo per three letters but always at least one. Short
+		// keywords are only one typo away from many other words, like "foo"
+		// from "for", so for them we only allow swapped letters.
+		maxDist := len(keyword) / 3
+		if maxDist < 1 {
+			maxDist = 1
+		}
+		dist := editDistance(id, keyword)
+		if len(keyword) <= 3 && dist > 0 && !isSwap(id, keyword) {
+			continue
+		}
+		if dist <= maxDist && (best == "" || dist < bestDist) {
+			best, bestDist = keyword, dist
+		}
+	}
+	return best
+}
+
+// isSwap reports whether a turns into b by swapping two adjacent runes.
+func isSwap(a, b string) bool {
+	s, t := []rune(a), []rune(b)
+	if len(s) != len(t) {
+		return false
+	}
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] != t[i] {
+			return s[i] == t[i+1] && s[i+1] == t[i] && string(s[i+2:]) == string(t[i+2:])
+		}
+	}
+	return false
+}
+
+// editDistance returns the number of rune insertions, deletions, substitutions
+// and swaps of adjacent runes that turn a into b.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// d[i][j] is the distance between s[:i] and t[:j].
+	d := make([][]int, len(s)+1)
+	for i := range d {
+		d[i] = make([]int, len(t)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s)][len(t)]
+}
+
+func minInt(first int, rest ...int) int {
+	m := first
+	for _, n := range rest {
+		if n < m {
+			m = n
+		}
+	}
+	return m
+}
@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SyntaxTree is the concrete syntax tree of some code. Unlike the Structogram,
+// which only keeps what is needed to draw and format the diagram, it keeps
+// every token of the code, including white space and comments. Writing the
+// tree back with String gives the code byte for byte. This way, a tool can
+// replace a single statement and leave the rest of the code as it was.
+type SyntaxTree struct {
+	// Structogram is the parsed code. It is not updated when the tree is
+	// edited.
+	Structogram *Structogram
+	// Root contains all tokens of the code. Its Node is the Structogram.
+	Root *SyntaxNode
+}
+
+// SyntaxNode is a node of the concrete syntax tree. It stands for a node of
+// the Structogram and has the tokens of that node and the syntax nodes of the
+// node's children, in the order of the code. Comments are tokens, they belong
+// to the innermost syntax node that surrounds them.
+type SyntaxNode struct {
+	Node     Node
+	Children []SyntaxElement
+}
+
+// SyntaxElement is a *SyntaxNode or a Token.
+type SyntaxElement interface {
+	Start() Pos
+	End() Pos
+	Text() string
+}
+
+// Start is the start of the node's first token.
+func (n *SyntaxNode) Start() Pos {
+	if len(n.Children) == 0 {
+		return n.Node.Start()
+	}
+	return n.Children[0].Start()
+}
+
+// End is the end of the node's last token.
+func (n *SyntaxNode) End() Pos {
+	if len(n.Children) == 0 {
+		return n.Node.End()
+	}
+	return n.Children[len(n.Children)-1].End()
+}
+
+// Text returns the code of all tokens in the node.
+func (n *SyntaxNode) Text() string {
+	var b strings.Builder
+	n.writeTo(&b)
+	return b.String()
+}
+
+func (n *SyntaxNode) writeTo(b *strings.Builder) {
+	for _, c := range n.Children {
+		if child, ok := c.(*SyntaxNode); ok {
+			child.writeTo(b)
+		} else {
+			b.WriteString(c.Text())
+		}
+	}
+}
+
+// Replace replaces the node's code by the given code, which must consist of
+// valid tokens. The node's children become the tokens of the new code, their
+// positions are relative to the new code. Parse the tree's String again to
+// get positions and a Structogram that match the edited code.
+func (n *SyntaxNode) Replace(code string) error {
+	tokens, errs := tokenize(code)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	n.Children = nil
+	for _, t := range tokens {
+		if t.typ != tokenEOF {
+			n.Children = append(n.Children, newToken(t))
+		}
+	}
+	return nil
+}
+
+// TokenKind tells what kind of text a Token is.
+type TokenKind int
+
+const (
+	KeywordToken TokenKind = iota
+	StringToken
+	SpaceToken
+	CommentToken
+	BraceToken
+)
+
+// Token is a piece of code in a syntax tree, like a keyword, a string literal
+// or a run of white space.
+type Token struct {
+	kind  TokenKind
+	text  string
+	start Pos
+	end   Pos
+}
+
+func (t Token) Kind() TokenKind { return t.kind }
+func (t Token) Text() string    { return t.text }
+func (t Token) Start() Pos      { return t.start }
+func (t Token) End() Pos        { return t.end }
+
+func newToken(t token) Token {
+	kind := BraceToken
+	switch t.typ {
+	case tokenID:
+		kind = KeywordToken
+	case tokenString:
+		kind = StringToken
+	case tokenSpace:
+		kind = SpaceToken
+	case tokenComment:
+		kind = CommentToken
+	}
+	return Token{kind: kind, text: t.text, start: t.start(), end: t.end()}
+}
+
+// ParseSyntaxTree parses the code like ParseString and returns its concrete
+// syntax tree.
+func ParseSyntaxTree(code string) (*SyntaxTree, error) {
+	s, err := ParseString(code)
+	if err != nil {
+		return nil, err
+	}
+	// Valid code has no tokenizer errors.
+	tokens, _ := tokenize(code)
+	tokens = tokens[:len(tokens)-1] // Drop EOF.
+
+	// First we build the syntax nodes for the Structogram's nodes, then we
+	// put the tokens in between them.
+	root := &SyntaxNode{Node: s}
+	children := map[*SyntaxNode][]*SyntaxNode{}
+	parents := []*SyntaxNode{}
+	Inspect(s, func(node Node) bool {
+		if node == nil {
+			parents = parents[:len(parents)-1]
+			return false
+		}
+		if _, ok := node.(Comment); ok {
+			return false
+		}
+		n := root
+		if len(parents) > 0 {
+			n = &SyntaxNode{Node: node}
+			parent := parents[len(parents)-1]
+			children[parent] = append(children[parent], n)
+		}
+		parents = append(parents, n)
+		return true
+	})
+
+	var fill func(n *SyntaxNode, end int)
+	// fill adds the tokens that end before the end offset to n and its
+	// children.
+	fill = func(n *SyntaxNode, end int) {
+		kids := children[n]
+		for len(tokens) > 0 && tokens[0].offset < end {
+			if len(kids) > 0 && tokens[0].offset >= kids[0].Node.Start().Offset {
+				fill(kids[0], kids[0].Node.End().Offset)
+				n.Children = append(n.Children, kids[0])
+				kids = kids[1:]
+			} else {
+				n.Children = append(n.Children, newToken(tokens[0]))
+				tokens = tokens[1:]
+			}
+		}
+	}
+	fill(root, len(code)+1)
+
+	return &SyntaxTree{Structogram: s, Root: root}, nil
+}
+
+// String returns the code of the tree. Unless it was edited, this is the code
+// that it was parsed from.
+func (t *SyntaxTree) String() string {
+	return t.Root.Text()
+}
+
+// Find returns the syntax node of the given node of the tree's Structogram or
+// nil if there is none.
+func (t *SyntaxTree) Find(node Node) *SyntaxNode {
+	var find func(n *SyntaxNode) *SyntaxNode
+	find = func(n *SyntaxNode) *SyntaxNode {
+		if n.Node.Start() == node.Start() && n.Node.End() == node.End() &&
+			reflect.TypeOf(n.Node) == reflect.TypeOf(node) {
+			return n
+		}
+		for _, c := range n.Children {
+			if child, ok := c.(*SyntaxNode); ok {
+				if found := find(child); found != nil {
+					return found
+				}
+			}
+		}
+		return nil
+	}
+	return find(t.Root)
+}
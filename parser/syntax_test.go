@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+)
+
+const oddlyFormattedCode = `// header
+title   "t"   /* title */
+
+"a"    // a
+
+
+if "b"{"c"}else "no"{
+		   "d"
+  }
+switch "e" { case "f" {} case default { break "" } /* end */ }
+parallel{{}{call "g"}}
+while{ do {} while "h" }   `
+
+func TestSyntaxTreeKeepsEveryByte(t *testing.T) {
+	tree, err := ParseSyntaxTree(oddlyFormattedCode)
+	check.Eq(t, err, nil)
+	check.Eq(t, tree.String(), oddlyFormattedCode)
+}
+
+func TestSyntaxTreeNeedsValidCode(t *testing.T) {
+	_, err := ParseSyntaxTree(`if {}`)
+	check.Eq(t, err.Error(), "parse error: 1:4: string expected but found token '{'")
+}
+
+func TestSyntaxNodesHaveTheTokensOfTheirNode(t *testing.T) {
+	tree, err := ParseSyntaxTree(`while "x" { "y" } // loop`)
+	check.Eq(t, err, nil)
+
+	root := tree.Root
+	check.Eq(t, root.Node, Node(tree.Structogram))
+	check.Eq(t, len(root.Children), 3)
+	check.Eq(t, root.Children[1].Text(), " ")
+	check.Eq(t, root.Children[2].(Token).Kind(), CommentToken)
+
+	loop := root.Children[0].(*SyntaxNode)
+	check.Eq(t, loop.Text(), `while "x" { "y" }`)
+	check.Eq(t, len(loop.Children), 5)
+	check.Eq(t, loop.Children[0].(Token).Kind(), KeywordToken)
+	check.Eq(t, loop.Children[1].(Token).Kind(), SpaceToken)
+	check.Eq(t, loop.Children[2].(*SyntaxNode).Node.(String).Text, "x")
+	check.Eq(t, loop.Children[3].(Token).Kind(), SpaceToken)
+	check.Eq(t, loop.Children[4].(*SyntaxNode).Text(), `{ "y" }`)
+	check.Eq(t, loop.Start(), Pos{Col: 1, Line: 1, Offset: 0})
+	check.Eq(t, loop.End(), Pos{Col: 18, Line: 1, Offset: 17})
+}
+
+func TestReplacingASyntaxNodeLeavesTheRestAsItWas(t *testing.T) {
+	tree, err := ParseSyntaxTree(oddlyFormattedCode)
+	check.Eq(t, err, nil)
+
+	ifElse := tree.Structogram.Statements[1].(IfElse)
+	n := tree.Find(ifElse.Else.Statements[0])
+	check.Eq(t, n.Text(), `"d"`)
+	check.Eq(t, n.Replace(`call "D" /* new */`), nil)
+
+	want := `// header
+title   "t"   /* title */
+
+"a"    // a
+
+
+if "b"{"c"}else "no"{
+		   call "D" /* new */
+  }
+switch "e" { case "f" {} case default { break "" } /* end */ }
+parallel{{}{call "g"}}
+while{ do {} while "h" }   `
+	check.Eq(t, tree.String(), want)
+	_, err = ParseString(tree.String())
+	check.Eq(t, err, nil)
+}
+
+func TestReplacingNeedsValidTokens(t *testing.T) {
+	tree, err := ParseSyntaxTree(`"a"`)
+	check.Eq(t, err, nil)
+	n := tree.Find(tree.Structogram.Statements[0])
+	check.Eq(t, n.Replace(`"b`).Error(), `parse error: 1:3: closing '"' of string expected but found end of input`)
+	check.Eq(t, tree.String(), `"a"`)
+}
+
+func TestFindReturnsNilForUnknownNodes(t *testing.T) {
+	tree, err := ParseSyntaxTree(`"a"`)
+	check.Eq(t, err, nil)
+	check.Eq(t, tree.Find(Instruction{Text: "b"}), (*SyntaxNode)(nil))
+}
@@ -2,23 +2,48 @@ package parser
 
 import (
 	"fmt"
+	"strconv"
 	"unicode"
 )
 
-func tokenize(code string) ([]token, error) {
-	var tokens []token
-	var err error
-	runes := []rune(code)
+// tokenize splits the code into tokens. Comments are kept as tokens so that
+// the parser can attach them to statements. tokenize does not stop at invalid
+// code, illegal characters and unterminated strings and comments are left out
+// of the tokens and reported in errs.
+func tokenize(code string) (tokens []token, errs ErrorList) {
+	// offsets are the byte offsets of the runes in code, followed by the
+	// length of the code. Invalid UTF-8 bytes are single runes.
+	var runes []rune
+	var offsets []int
+	for i, r := range code {
+		runes = append(runes, r)
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(code))
 
 	// pos is the current index into runes, col and line are 1-indexed position
 	// info for error messages.
 	pos, col, line := 0, 1, 1
 
-	// EOF indicates the end of file.
-	const EOF = 0
+	// EOF indicates the end of file. It is not a valid rune so NUL characters
+	// in the code are not mistaken for the end.
+	const EOF = -1
 
-	makeErr := func(msg string) error {
-		return fmt.Errorf("%d:%d: %s", line, col, msg)
+	// report adds an error for the offending character at the current
+	// position.
+	report := func(expected, found string) {
+		start := Pos{Col: col, Line: line, Offset: offsets[pos]}
+		end := start
+		if pos < len(runes) {
+			end.Col++
+			end.Offset = offsets[pos+1]
+		}
+		errs = append(errs, &Error{
+			Start:    start,
+			End:      end,
+			Expected: expected,
+			Found:    found,
+		})
 	}
 
 	cur := func() rune {
@@ -27,6 +52,12 @@ func tokenize(code string) ([]token, error) {
 		}
 		return EOF
 	}
+	peek := func() rune {
+		if pos+1 < len(runes) {
+			return runes[pos+1]
+		}
+		return EOF
+	}
 	next := func() {
 		if pos < len(runes) {
 			col++
@@ -45,18 +76,25 @@ func tokenize(code string) ([]token, error) {
 	startLine := line
 	emit := func(typ tokenType) {
 		tokens = append(tokens, token{
-			typ:  typ,
-			text: string(runes[startPos:pos]),
-			col:  startCol,
-			line: startLine,
+			typ:    typ,
+			text:   code[offsets[startPos]:offsets[pos]],
+			col:    startCol,
+			line:   startLine,
+			offset: offsets[startPos],
 		})
 		startPos = pos
 		startCol = col
 		startLine = line
 	}
+	// discard drops the code since the last token instead of emitting it.
+	discard := func() {
+		startPos = pos
+		startCol = col
+		startLine = line
+	}
 
 	// The main tokenize loop uses only the helper functions declared above.
-	for cur() != EOF {
+	for pos < len(runes) {
 		switch cur() {
 		case '{', '}':
 			typ := tokenType(cur())
@@ -64,27 +102,23 @@ func tokenize(code string) ([]token, error) {
 			emit(typ)
 		case '"':
 			next()
-			for {
-				if cur() == '"' {
-					next() // Skip the closing quote.
-					break
-				} else if cur() == '\\' {
+			for cur() != '"' && cur() != EOF {
+				if cur() == '\\' {
 					// Escape sequence "\\", "\"" or "\n".
 					next()
-					if cur() == '\\' || cur() == 'n' || cur() == '"' {
-						next()
-					} else if cur() == EOF {
-						return nil, makeErr("unexpected end of input after '\\' in string literal")
-					} else {
-						return nil, makeErr("unknown escape sequence (only 'n', '\\' and '\"' can follow after '\\')")
+					if cur() != '\\' && cur() != 'n' && cur() != '"' && cur() != EOF {
+						report(escapeSequences, fmt.Sprintf("escape sequence '\\%c'", cur()))
 					}
-				} else if cur() == EOF {
-					return nil, makeErr("unexpected end of input in string literal")
-				} else {
-					next()
 				}
+				next()
+			}
+			if cur() == '"' {
+				next() // Skip the closing quote.
+				emit(tokenString)
+			} else {
+				report("closing '\"' of string", "end of input")
+				discard()
 			}
-			emit(tokenString)
 		default:
 			if unicode.IsSpace(cur()) {
 				for unicode.IsSpace(cur()) {
@@ -96,30 +130,85 @@ func tokenize(code string) ([]token, error) {
 					next()
 				}
 				emit(tokenID)
+			} else if cur() == '/' && peek() == '/' {
+				for cur() != '\n' && cur() != EOF {
+					next()
+				}
+				emit(tokenComment)
+			} else if cur() == '/' && peek() == '*' {
+				next()
+				next()
+				for !(cur() == '*' && peek() == '/') && cur() != EOF {
+					next()
+				}
+				if cur() == EOF {
+					report("closing '*/' of comment", "end of input")
+					discard()
+				} else {
+					next()
+					next()
+					emit(tokenComment)
+				}
 			} else {
-				return nil, makeErr("illegal character " + fmt.Sprintf("%q", cur()))
+				report("", fmt.Sprintf("character %q", cur()))
+				next()
+				discard()
 			}
 		}
 	}
 
 	emit(tokenEOF)
 
-	return tokens, err
+	return tokens, errs
 }
 
+// escapeSequences describes what can follow after '\\' in a string.
+const escapeSequences = `escape sequence '\n', '\\' or '\"'`
+
 type token struct {
-	typ  tokenType
-	text string
-	col  int
-	line int
+	typ    tokenType
+	text   string
+	col    int
+	line   int
+	offset int
+}
+
+func (t token) start() Pos {
+	return Pos{Col: t.col, Line: t.line, Offset: t.offset}
+}
+
+// end is the position right after the token.
+func (t token) end() Pos {
+	end := Pos{Col: t.col, Line: t.line, Offset: t.offset + len(t.text)}
+	for _, r := range t.text {
+		end.Col++
+		if r == '\n' {
+			end.Col = 1
+			end.Line++
+		}
+	}
+	return end
+}
+
+// describe describes the token for error messages.
+func (t token) describe() string {
+	switch t.typ {
+	case tokenID:
+		return "identifier " + strconv.Quote(t.text)
+	case tokenString:
+		return "string " + t.text
+	default:
+		return t.typ.String()
+	}
 }
 
 type tokenType int
 
 const (
-	tokenID = iota
+	tokenID tokenType = iota
 	tokenString
 	tokenSpace
+	tokenComment
 	tokenEOF
 )
 
@@ -131,6 +220,10 @@ func (t tokenType) String() string {
 		return "string"
 	case tokenSpace:
 		return "white space"
+	case tokenComment:
+		return "comment"
+	case tokenEOF:
+		return "end of input"
 	default:
 		return fmt.Sprintf("token %q", rune(t))
 	}
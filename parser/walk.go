@@ -0,0 +1,232 @@
+package parser
+
+import "fmt"
+
+// A Visitor's Visit method is called by Walk for every node. If it returns a
+// non-nil Visitor w, Walk visits the node's children with w, followed by a
+// call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the syntax tree in the order of the code, starting with node.
+// It calls v.Visit(node) and, unless that returns nil, walks the node's
+// children with the returned visitor. The children of a node are its comments,
+// Strings, Blocks, SwitchCases and statements. Strings that are left out of
+// the code, like an If's missing TrueText, are not visited.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Structogram:
+		walkComments(v, n.Leading)
+		if n.Title.given() {
+			Walk(v, n.Title)
+		}
+		walkComments(v, n.Trailing)
+		walkStatements(v, n.Statements)
+		walkComments(v, n.EndComments)
+
+	case String, Comment, Keyword:
+		// Leaves have no children.
+
+	case Instruction:
+		walkComments(v, n.Leading)
+		walkComments(v, n.Trailing)
+
+	case Call:
+		walkComments(v, n.Leading)
+		walkComments(v, n.Trailing)
+
+	case Break:
+		walkComments(v, n.Leading)
+		walkComments(v, n.Trailing)
+
+	case Return:
+		walkComments(v, n.Leading)
+		walkComments(v, n.Trailing)
+
+	case Continue:
+		walkComments(v, n.Leading)
+		walkComments(v, n.Trailing)
+
+	case Exit:
+		walkComments(v, n.Leading)
+		walkComments(v, n.Trailing)
+
+	case BadStatement:
+		walkComments(v, n.Leading)
+		walkComments(v, n.Trailing)
+
+	case Block:
+		walkComments(v, n.Leading)
+		walkStatements(v, n.Statements)
+		walkComments(v, n.EndComments)
+		walkComments(v, n.Trailing)
+
+	case If:
+		walkComments(v, n.Leading)
+		Walk(v, n.Condition)
+		if n.TrueText.given() {
+			Walk(v, n.TrueText)
+		}
+		Walk(v, n.Then)
+		walkComments(v, n.Trailing)
+
+	case IfElse:
+		walkComments(v, n.Leading)
+		Walk(v, n.Condition)
+		if n.TrueText.given() {
+			Walk(v, n.TrueText)
+		}
+		Walk(v, n.Then)
+		if n.FalseText.given() {
+			Walk(v, n.FalseText)
+		}
+		Walk(v, n.Else)
+		walkComments(v, n.Trailing)
+
+	case IfChain:
+		walkComments(v, n.Leading)
+		Walk(v, n.Condition)
+		if n.TrueText.given() {
+			Walk(v, n.TrueText)
+		}
+		Walk(v, n.Then)
+		for _, e := range n.ElseIfs {
+			Walk(v, e)
+		}
+		if n.FalseText.given() {
+			Walk(v, n.FalseText)
+		}
+		if n.HasElse {
+			Walk(v, n.Else)
+		}
+		walkComments(v, n.Trailing)
+
+	case ElseIf:
+		Walk(v, n.Condition)
+		if n.TrueText.given() {
+			Walk(v, n.TrueText)
+		}
+		Walk(v, n.Block)
+
+	case Try:
+		walkComments(v, n.Leading)
+		Walk(v, n.Block)
+		for _, c := range n.Catches {
+			Walk(v, c)
+		}
+		if n.HasFinally {
+			Walk(v, n.Finally)
+		}
+		walkComments(v, n.Trailing)
+
+	case Catch:
+		if n.Exception.given() {
+			Walk(v, n.Exception)
+		}
+		Walk(v, n.Block)
+
+	case Switch:
+		walkComments(v, n.Leading)
+		Walk(v, n.Subject)
+		for _, c := range n.Cases {
+			Walk(v, c)
+		}
+		walkComments(v, n.EndComments)
+		walkComments(v, n.Trailing)
+
+	case SwitchCase:
+		walkComments(v, n.Leading)
+		if !n.IsDefault || n.Condition.given() {
+			Walk(v, n.Condition)
+		}
+		Walk(v, n.Block)
+		walkComments(v, n.Trailing)
+
+	case Parallel:
+		walkComments(v, n.Leading)
+		for _, b := range n.Blocks {
+			Walk(v, b)
+		}
+		walkComments(v, n.EndComments)
+		walkComments(v, n.Trailing)
+
+	case InfiniteLoop:
+		walkComments(v, n.Leading)
+		Walk(v, n.Block)
+		walkComments(v, n.Trailing)
+
+	case While:
+		walkComments(v, n.Leading)
+		Walk(v, n.Condition)
+		Walk(v, n.Block)
+		walkComments(v, n.Trailing)
+
+	case DoWhile:
+		walkComments(v, n.Leading)
+		Walk(v, n.Block)
+		Walk(v, n.Condition)
+		walkComments(v, n.Trailing)
+
+	case RepeatUntil:
+		walkComments(v, n.Leading)
+		Walk(v, n.Block)
+		Walk(v, n.Condition)
+		walkComments(v, n.Trailing)
+
+	case For:
+		walkComments(v, n.Leading)
+		Walk(v, n.Variable)
+		Walk(v, n.From)
+		Walk(v, n.To)
+		if n.Step.given() {
+			Walk(v, n.Step)
+		}
+		Walk(v, n.Block)
+		walkComments(v, n.Trailing)
+
+	case Foreach:
+		walkComments(v, n.Leading)
+		Walk(v, n.Variable)
+		Walk(v, n.Collection)
+		Walk(v, n.Block)
+		walkComments(v, n.Trailing)
+
+	default:
+		panic(fmt.Sprintf("parser.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, statements []Statement) {
+	for _, s := range statements {
+		Walk(v, s)
+	}
+}
+
+func walkComments(v Visitor, comments []Comment) {
+	for _, c := range comments {
+		Walk(v, c)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the syntax tree like Walk, calling f(node) for every node.
+// If f returns true, Inspect visits the node's children, followed by a call of
+// f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
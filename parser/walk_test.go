@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+)
+
+func TestInspectVisitsAllNodesInOrder(t *testing.T) {
+	s, err := ParseString(`title "t"
+// comment
+"a"
+if "b" "yes" { call "c" } else { break "" }
+switch "d" {
+	case "e" {}
+	case default {}
+}
+parallel { { while "f" {} } { while {} } }
+do {} while "g"`)
+	check.Eq(t, err, nil)
+
+	var visited []string
+	Inspect(s, func(n Node) bool {
+		switch n := n.(type) {
+		case nil:
+			visited = append(visited, "end")
+		case String:
+			visited = append(visited, fmt.Sprintf("String %q", n.Text))
+		case Comment:
+			visited = append(visited, "Comment "+n.Text)
+		default:
+			visited = append(visited, strings.Replace(fmt.Sprintf("%T", n), "parser.", "", 1))
+		}
+		return true
+	})
+	check.Eq(t, visited, []string{
+		"*Structogram",
+		`String "t"`, "end",
+		"Instruction", "Comment // comment", "end", "end",
+		"IfElse",
+		`String "b"`, "end",
+		`String "yes"`, "end",
+		"Block", "Call", "end", "end",
+		"Block", "Break", "end", "end",
+		"end",
+		"Switch",
+		`String "d"`, "end",
+		"SwitchCase", `String "e"`, "end", "Block", "end", "end",
+		"SwitchCase", "Block", "end", "end",
+		"end",
+		"Parallel",
+		"Block", "While", `String "f"`, "end", "Block", "end", "end", "end",
+		"Block", "InfiniteLoop", "Block", "end", "end", "end",
+		"end",
+		"DoWhile", "Block", "end", `String "g"`, "end", "end",
+		"end",
+	})
+}
+
+func TestInspectCanSkipSubtrees(t *testing.T) {
+	s, err := ParseString(`
+"a"
+while "b" { "c" }
+parallel { { "d" } }`)
+	check.Eq(t, err, nil)
+
+	var instructions []string
+	Inspect(s, func(n Node) bool {
+		if i, ok := n.(Instruction); ok {
+			instructions = append(instructions, i.Text)
+		}
+		_, isLoop := n.(While)
+		return !isLoop
+	})
+	check.Eq(t, instructions, []string{"a", "d"})
+}
+
+type countingVisitor map[string]int
+
+func (c countingVisitor) Visit(n Node) Visitor {
+	c[fmt.Sprintf("%T", n)]++
+	return c
+}
+
+func TestWalkCallsVisitWithNilAfterChildren(t *testing.T) {
+	s := NewBuilder("").Instr("a").Instr("b").Structogram()
+	count := countingVisitor{}
+	Walk(count, s)
+	check.Eq(t, count, countingVisitor{
+		"*parser.Structogram": 1,
+		"parser.Instruction":  2,
+		"<nil>":               3,
+	})
+}
+
+func TestStructogramPosition(t *testing.T) {
+	s, err := ParseString("\n  \"a\"\n  \"bc\"  ")
+	check.Eq(t, err, nil)
+	check.Eq(t, s.Start(), Pos{Col: 3, Line: 2, Offset: 3})
+	check.Eq(t, s.End(), Pos{Col: 7, Line: 3, Offset: 13})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// pdfPainter paints vector graphics and text into the current page of a PDF
+// document. The document's unit is expected to be points, one pixel is painted
+// as one point.
+type pdfPainter struct {
+	pdf  *gofpdf.Fpdf
+	font vectorFont
+}
+
+// newPDFPainter paints into the given document, the font must have been set
+// up for the same document, see newHelvetica and loadVectorFont.
+func newPDFPainter(pdf *gofpdf.Fpdf, font vectorFont) pdfPainter {
+	pdf.SetLineWidth(1)
+	pdf.SetLineCapStyle("square")
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetTextColor(0, 0, 0)
+	return pdfPainter{pdf: pdf, font: font}
+}
+
+func (p pdfPainter) Text(x, y int, s string) {
+	baseline := p.font.baseline(y)
+	lineH := float64(p.LineHeight())
+	for i, line := range strings.Split(s, "\n") {
+		if line != "" {
+			p.pdf.Text(float64(x), baseline+float64(i)*lineH, p.font.encode(line))
+		}
+	}
+}
+
+func (p pdfPainter) TextSize(s string) (width, height int) {
+	return p.font.textSize(s)
+}
+
+// Lines and rectangles are offset by half a pixel, like in the svgPainter, so
+// that pixel coordinates are at the centers of the strokes.
+
+func (p pdfPainter) Rect(x, y, width, height int) {
+	p.pdf.Rect(
+		float64(x)+0.5, float64(y)+0.5,
+		float64(width-1), float64(height-1),
+		"D",
+	)
+}
+
+func (p pdfPainter) Line(x1, y1, x2, y2 int) {
+	p.pdf.Line(
+		float64(x1)+0.5, float64(y1)+0.5,
+		float64(x2)+0.5, float64(y2)+0.5,
+	)
+}
+
+func (p pdfPainter) LineHeight() int {
+	return p.font.lineHeight()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/jung-kurt/gofpdf"
+)
+
+func TestPDFTextIsWrittenAsText(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	pdf.AddPage()
+	p := newPDFPainter(pdf, newHelvetica(pdf, 16))
+	p.Text(10, 20, "first line\nsecond line")
+	var buf bytes.Buffer
+	check.Eq(t, pdf.Output(&buf), nil)
+	// The PDF y-axis points up, the A4 page is 841.89 points high. The lines'
+	// baselines are 20 points apart.
+	pdfText := buf.String()
+	check.Eq(t, strings.Contains(pdfText, "BT 10.00 807.80 Td (first line) Tj ET"), true)
+	check.Eq(t, strings.Contains(pdfText, "BT 10.00 787.80 Td (second line) Tj ET"), true)
+}
+
+func TestPDFPainterMeasuresLikeSVGPainter(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.AddPage()
+	p := newPDFPainter(pdf, newHelvetica(pdf, 16))
+	svg := newSVGPainter(16)
+	check.Eq(t, p.LineHeight(), svg.LineHeight())
+	pdfW, pdfH := p.TextSize("some text\nwith two lines")
+	svgW, svgH := svg.TextSize("some text\nwith two lines")
+	check.Eq(t, pdfW, svgW)
+	check.Eq(t, pdfH, svgH)
+}
+
+func TestPDFEmbedsTrueTypeFontForNonLatinText(t *testing.T) {
+	pdf := gofpdf.New("P", "pt", "A4", "")
+	pdf.SetCompression(false)
+	font, err := loadVectorFont(pdf, 16, testFontPath(t))
+	check.Eq(t, err, nil)
+	check.Eq(t, font.unicode, true)
+	check.Eq(t, font.canEncode("Ωμέγα"), true)
+
+	// Helvetica would replace the Greek letters by dots, the TrueType font
+	// measures the real glyphs.
+	helvetica := newHelvetica(gofpdf.New("P", "pt", "A4", ""), 16)
+	check.Eq(t, helvetica.canEncode("Ωμέγα"), false)
+	check.Eq(t, font.lineWidth("Ωμέγα") != helvetica.lineWidth("....."), true)
+
+	pdf.AddPage()
+	p := newPDFPainter(pdf, font)
+	p.Text(10, 20, "Ωμέγα")
+	var buf bytes.Buffer
+	check.Eq(t, pdf.Output(&buf), nil)
+	// The text is written as glyph IDs of the embedded font subset.
+	check.Eq(t, strings.Contains(buf.String(), "/FontFile2"), true)
+	check.Eq(t, strings.Contains(buf.String(), "(.....) Tj"), false)
+}
+
+func TestPDFFontGivenExplicitlyMustExist(t *testing.T) {
+	_, err := loadVectorFont(gofpdf.New("P", "pt", "A4", ""), 16, "does/not/exist.ttf")
+	check.Eq(t, err != nil, true)
+}
+
+// testFontPath returns a TrueType font with Greek letters. These are the
+// system's default fonts or the DejaVu font that comes with gofpdf.
+func testFontPath(t *testing.T) string {
+	for _, path := range defaultFontPaths {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	dir, err := exec.Command(
+		"go", "list", "-m", "-f", "{{.Dir}}", "github.com/jung-kurt/gofpdf",
+	).Output()
+	path := filepath.Join(strings.TrimSpace(string(dir)), "font", "DejaVuSansCondensed.ttf")
+	if _, statErr := os.Stat(path); err != nil || statErr != nil {
+		t.Skip("no TrueType font found")
+	}
+	return path
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// svgFontFamily names fonts that have the same metrics as Helvetica, which we
+// use to measure text.
+const svgFontFamily = "Helvetica, Arial, sans-serif"
+
+// svgPainter collects SVG elements for everything that is painted.
+type svgPainter struct {
+	elements bytes.Buffer
+	font     vectorFont
+}
+
+func newSVGPainter(fontSize float64) *svgPainter {
+	return &svgPainter{font: newHelvetica(gofpdf.New("P", "pt", "A4", ""), fontSize)}
+}
+
+func (p *svgPainter) Text(x, y int, s string) {
+	baseline := p.font.baseline(y)
+	lineH := float64(p.LineHeight())
+	for i, line := range strings.Split(s, "\n") {
+		if line == "" {
+			continue
+		}
+		fmt.Fprintf(
+			&p.elements,
+			`<text x="%d" y="%s"`,
+			x,
+			svgNumber(baseline+float64(i)*lineH),
+		)
+		// textLength makes the text exactly as wide as we measured it, in case
+		// the viewer does not have a font with the same metrics. Helvetica
+		// does not know all characters though, we only estimate the width of
+		// the others and let the viewer's font decide.
+		if p.font.canEncode(line) {
+			fmt.Fprintf(
+				&p.elements,
+				` textLength="%s" lengthAdjust="spacingAndGlyphs"`,
+				svgNumber(p.font.lineWidth(line)),
+			)
+		}
+		p.elements.WriteString(">")
+		xml.EscapeText(&p.elements, []byte(line))
+		p.elements.WriteString("</text>\n")
+	}
+}
+
+func (p *svgPainter) TextSize(s string) (width, height int) {
+	return p.font.textSize(s)
+}
+
+// Lines and rectangles are offset by half a pixel so that their one pixel wide
+// strokes cover exactly one row or column of pixels.
+
+func (p *svgPainter) Rect(x, y, width, height int) {
+	fmt.Fprintf(
+		&p.elements,
+		`<rect x="%s" y="%s" width="%d" height="%d" stroke="black" fill="none"/>`+"\n",
+		svgNumber(float64(x)+0.5), svgNumber(float64(y)+0.5), width-1, height-1,
+	)
+}
+
+func (p *svgPainter) Line(x1, y1, x2, y2 int) {
+	fmt.Fprintf(
+		&p.elements,
+		`<line x1="%s" y1="%s" x2="%s" y2="%s" stroke="black"/>`+"\n",
+		svgNumber(float64(x1)+0.5), svgNumber(float64(y1)+0.5),
+		svgNumber(float64(x2)+0.5), svgNumber(float64(y2)+0.5),
+	)
+}
+
+func (p *svgPainter) LineHeight() int {
+	return p.font.lineHeight()
+}
+
+// svgNumber formats f with at most two decimal places.
+func svgNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
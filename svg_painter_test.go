@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+)
+
+func TestSVGLinesCoverPixelCenters(t *testing.T) {
+	p := newSVGPainter(16)
+	p.Line(0, 0, 10, 5)
+	p.Rect(1, 2, 30, 20)
+	check.Eq(t, p.elements.String(),
+		`<line x1="0.5" y1="0.5" x2="10.5" y2="5.5" stroke="black"/>`+"\n"+
+			`<rect x="1.5" y="2.5" width="29" height="19" stroke="black" fill="none"/>`+"\n",
+	)
+}
+
+func TestSVGTextIsEscapedAndSplitIntoLines(t *testing.T) {
+	p := newSVGPainter(16)
+	check.Eq(t, p.LineHeight(), 20)
+	w, h := p.TextSize("a<b\n\n&")
+	// "a<b" is (0.556+0.584+0.556)*16 = 27.136 wide, rounded up.
+	check.Eq(t, w, 28)
+	check.Eq(t, h, 3*20)
+
+	p.Text(5, 10, "a<b\n\n&")
+	check.Eq(t, p.elements.String(),
+		`<text x="5" y="24.09" textLength="27.14" lengthAdjust="spacingAndGlyphs">a&lt;b</text>`+"\n"+
+			`<text x="5" y="64.09" textLength="10.67" lengthAdjust="spacingAndGlyphs">&amp;</text>`+"\n",
+	)
+}
+
+func TestSVGTextThatHelveticaCannotMeasureHasNoTextLength(t *testing.T) {
+	p := newSVGPainter(16)
+	// Characters that are not in Helvetica are estimated as one em wide.
+	w, _ := p.TextSize("aΩ")
+	check.Eq(t, w, 9+16)
+
+	p.Text(0, 0, "aΩ\na")
+	check.Eq(t, p.elements.String(),
+		`<text x="0" y="14.09">aΩ</text>`+"\n"+
+			`<text x="0" y="34.09" textLength="8.9" lengthAdjust="spacingAndGlyphs">a</text>`+"\n",
+	)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"unicode/utf8"
+
+	"github.com/gonutz/structorama/parser"
+)
+
+// characterIndex returns the index of the character at the given position in
+// code, as used for selections in text edits. Windows edit controls count
+// UTF-16 code units, characters outside the Basic Multilingual Plane, like
+// most emoji, count as two.
+func characterIndex(code string, pos parser.Pos) int {
+	if pos.Offset > len(code) {
+		pos.Offset = len(code)
+	}
+	index := 0
+	for _, r := range code[:pos.Offset] {
+		index += utf16Len(r)
+	}
+	return index
+}
+
+// byteOffset is the inverse of characterIndex, it returns the byte offset of
+// the character with the given index in code.
+func byteOffset(code string, index int) int {
+	for offset, r := range code {
+		if index <= 0 {
+			return offset
+		}
+		index -= utf16Len(r)
+	}
+	return len(code)
+}
+
+// utf16Len returns the number of UTF-16 code units that encode r. Runes beyond
+// the Basic Multilingual Plane need a surrogate pair, all others need one unit.
+// Invalid runes are encoded as the replacement character, which has one unit.
+func utf16Len(r rune) int {
+	if r > 0xFFFF && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+
+	"github.com/gonutz/structorama/parser"
+)
+
+func TestCharacterIndexCountsUTF16CodeUnits(t *testing.T) {
+	// "ä" is two bytes and one UTF-16 unit, the emoji is four bytes and a
+	// surrogate pair of two UTF-16 units.
+	code := "ä😀\"a\""
+	check.Eq(t, characterIndex(code, parser.Pos{Offset: 0}), 0)
+	check.Eq(t, characterIndex(code, parser.Pos{Offset: 2}), 1)
+	check.Eq(t, characterIndex(code, parser.Pos{Offset: 6}), 3)
+	check.Eq(t, characterIndex(code, parser.Pos{Offset: 9}), 6)
+	check.Eq(t, characterIndex(code, parser.Pos{Offset: 100}), 6)
+
+	check.Eq(t, byteOffset(code, 0), 0)
+	check.Eq(t, byteOffset(code, 1), 2)
+	check.Eq(t, byteOffset(code, 3), 6)
+	check.Eq(t, byteOffset(code, 6), 9)
+	check.Eq(t, byteOffset(code, 100), 9)
+}
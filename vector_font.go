@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"os"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Helvetica's ascent and descent relative to the font size.
+const (
+	helveticaAscent  = 0.718
+	helveticaDescent = 0.207
+)
+
+// textLineSpacing is the height of a line of text relative to the font size.
+const textLineSpacing = 1.25
+
+// embeddedFontFamily is the name under which TrueType fonts are added to PDF
+// documents.
+const embeddedFontFamily = "text"
+
+// vectorFont measures text for painters that produce vector output. It is
+// either the PDF core font Helvetica, which needs no font file but only knows
+// the characters of code page 1252, or a TrueType font that is embedded in the
+// PDF document and supports all of Unicode.
+type vectorFont struct {
+	size float64
+	pdf  *gofpdf.Fpdf
+	// ascent and descent are relative to the font size.
+	ascent, descent float64
+	// encode converts UTF-8 text to the encoding of the font.
+	encode func(string) string
+	// unicode is true for TrueType fonts, they need no conversion.
+	unicode bool
+}
+
+// newHelvetica sets the font of the given PDF document and uses it for
+// measuring text. gofpdf ships the metrics of the standard PDF fonts so this
+// works without any font files.
+func newHelvetica(pdf *gofpdf.Fpdf, size float64) vectorFont {
+	pdf.SetFont("Helvetica", "", size)
+	return vectorFont{
+		size:    size,
+		pdf:     pdf,
+		ascent:  helveticaAscent,
+		descent: helveticaDescent,
+		encode:  pdf.UnicodeTranslatorFromDescriptor(""),
+	}
+}
+
+// loadVectorFont embeds the TrueType font at the given path in the PDF
+// document, sets it as the document's font and uses it for measuring text. If
+// path is empty, the first of the defaultFontPaths that exists is used. If none
+// exists, we fall back to Helvetica.
+func loadVectorFont(pdf *gofpdf.Fpdf, size float64, path string) (vectorFont, error) {
+	paths := []string{path}
+	if path == "" {
+		paths = defaultFontPaths
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if len(paths) == 1 {
+				return vectorFont{}, err
+			}
+			continue
+		}
+		pdf.AddUTF8FontFromBytes(embeddedFontFamily, "", data)
+		pdf.SetFont(embeddedFontFamily, "", size)
+		if err := pdf.Error(); err != nil {
+			return vectorFont{}, err
+		}
+		desc := pdf.GetFontDesc("", "")
+		return vectorFont{
+			size:    size,
+			pdf:     pdf,
+			ascent:  float64(desc.Ascent) / 1000,
+			descent: -float64(desc.Descent) / 1000,
+			encode:  func(s string) string { return s },
+			unicode: true,
+		}, nil
+	}
+	return newHelvetica(pdf, size), nil
+}
+
+// canEncode reports whether the font has all characters of s.
+func (f vectorFont) canEncode(s string) bool {
+	if f.unicode {
+		return true
+	}
+	// The code page conversion replaces unknown characters with dots.
+	for _, r := range s {
+		if r != '.' && f.encode(string(r)) == "." {
+			return false
+		}
+	}
+	return true
+}
+
+// lineWidth returns the width of a single line of text. Characters that the
+// font does not have are counted as one em wide, which is enough for most
+// scripts, so their boxes are large enough even if we cannot measure them.
+func (f vectorFont) lineWidth(line string) float64 {
+	if f.canEncode(line) {
+		return f.pdf.GetStringWidth(f.encode(line))
+	}
+	w := 0.0
+	for _, r := range line {
+		if f.canEncode(string(r)) {
+			w += f.pdf.GetStringWidth(f.encode(string(r)))
+		} else {
+			w += f.size
+		}
+	}
+	return w
+}
+
+func (f vectorFont) lineHeight() int {
+	return int(f.size*textLineSpacing + 0.5)
+}
+
+func (f vectorFont) textSize(s string) (width, height int) {
+	lines := strings.Split(s, "\n")
+	w := 0.0
+	for _, line := range lines {
+		w = math.Max(w, f.lineWidth(line))
+	}
+	return int(math.Ceil(w)), len(lines) * f.lineHeight()
+}
+
+// baseline returns the baseline of the first line of text painted with its top
+// at y. The glyphs are centered vertically in their line.
+func (f vectorFont) baseline(y int) float64 {
+	return float64(y) +
+		(float64(f.lineHeight())-(f.ascent+f.descent)*f.size)/2 +
+		f.ascent*f.size
+}